@@ -5,13 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 
+	"tidb-benchmarks/pkg/compare"
 	"tidb-benchmarks/pkg/config"
 	"tidb-benchmarks/pkg/db"
+	"tidb-benchmarks/pkg/metrics"
 	"tidb-benchmarks/pkg/report"
+	"tidb-benchmarks/pkg/scenario"
 	"tidb-benchmarks/pkg/workload"
 )
 
@@ -24,6 +28,7 @@ func main() {
 
 func run() error {
 	cfg := config.Default()
+	cfg.OnInterval = func(iv metrics.Interval) { report.PrintInterval(cfg.Output, iv) }
 
 	root := &cobra.Command{
 		Use:           "bench",
@@ -60,35 +65,69 @@ func run() error {
 		Short: "Run a workload",
 	}
 
-	readOnlyCmd := &cobra.Command{
-		Use:   "read-only",
-		Short: "Point reads only",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			return runWorkload(cmd, cfg, workload.KindReadOnly)
-		},
+	workload.BindPrepareFlags(prepareCmd.Flags(), &cfg)
+	workload.BindRunFlags(runCmd.PersistentFlags(), &cfg)
+	workload.BindSweepFlags(runCmd.PersistentFlags(), &cfg)
+	workload.BindRepeatFlags(runCmd.PersistentFlags(), &cfg)
+	runCmd.AddCommand(workloadCommands(&cfg, func(cmd *cobra.Command, kind workload.Kind) error {
+		return runWorkload(cmd, cfg, kind)
+	})...)
+
+	searchCmd := &cobra.Command{
+		Use:   "search",
+		Short: "Find the highest rate or thread count that meets a p99 objective",
 	}
+	workload.BindRunFlags(searchCmd.PersistentFlags(), &cfg)
+	workload.BindSearchFlags(searchCmd.PersistentFlags(), &cfg)
+	searchCmd.AddCommand(workloadCommands(&cfg, func(cmd *cobra.Command, kind workload.Kind) error {
+		return searchWorkload(cmd, cfg, kind)
+	})...)
 
-	writeOnlyCmd := &cobra.Command{
-		Use:   "write-only",
-		Short: "Updates only",
+	compareCmd := &cobra.Command{
+		Use:   "compare <baseline.json> <result.json>...",
+		Short: "Compare saved JSON results against the first and check regression thresholds",
+		Args:  cobra.MinimumNArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runWorkload(cmd, cfg, workload.KindWriteOnly)
+			res, err := compare.Compare(cfg, args)
+			if err != nil {
+				return err
+			}
+			if err := report.PrintComparison(cfg.Output, res); err != nil {
+				return err
+			}
+			if n := len(res.Regressions); n > 0 {
+				return fmt.Errorf("%d regression(s) past the thresholds", n)
+			}
+			return nil
 		},
 	}
+	compare.BindFlags(compareCmd.Flags(), &cfg)
 
-	mixedCmd := &cobra.Command{
-		Use:   "mixed",
-		Short: "Mixed reads and writes",
+	scenarioCmd := &cobra.Command{
+		Use:   "scenario",
+		Short: "Run multi-phase benchmarks defined in YAML files",
+	}
+	scenarioRunCmd := &cobra.Command{
+		Use:   "run <file.yaml>",
+		Short: "Run the phases of a scenario file in order",
+		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runWorkload(cmd, cfg, workload.KindMixed)
+			sc, err := scenario.Load(args[0])
+			if err != nil {
+				return err
+			}
+			res, err := scenario.Run(cmd.Context(), cfg, sc)
+			if len(res.Phases) > 0 {
+				if perr := report.PrintScenario(cfg.Output, res); perr != nil && err == nil {
+					err = perr
+				}
+			}
+			return err
 		},
 	}
+	scenarioCmd.AddCommand(scenarioRunCmd)
 
-	workload.BindRunFlags(runCmd.PersistentFlags(), &cfg)
-	workload.BindMixedFlags(mixedCmd.Flags(), &cfg)
-
-	runCmd.AddCommand(readOnlyCmd, writeOnlyCmd, mixedCmd)
-	root.AddCommand(prepareCmd, runCmd)
+	root.AddCommand(prepareCmd, runCmd, searchCmd, compareCmd, scenarioCmd)
 
 	if err := root.Execute(); err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
@@ -99,10 +138,79 @@ func run() error {
 	return nil
 }
 
+// workloadCommands returns one subcommand per workload kind, with the
+// flags of that kind bound to cfg, each calling runE.
+func workloadCommands(cfg *config.Config, runE func(cmd *cobra.Command, kind workload.Kind) error) []*cobra.Command {
+	command := func(use, short string, kind workload.Kind) *cobra.Command {
+		return &cobra.Command{
+			Use:   use,
+			Short: short,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runE(cmd, kind)
+			},
+		}
+	}
+	ycsb := func(name, short string, kind workload.Kind) *cobra.Command {
+		return command("ycsb-"+name, "YCSB workload "+strings.ToUpper(name)+", "+short, kind)
+	}
+
+	mixedCmd := command("mixed", "Mixed reads and writes", workload.KindMixed)
+	rangeScanCmd := command("range-scan", "Short range scans only", workload.KindRangeScan)
+	oltpCmd := command("oltp-read-write", "sysbench oltp_read_write transactions", workload.KindOLTPReadWrite)
+	bankCmd := command("bank", "Concurrent transfers between accounts with total-balance checks", workload.KindBank)
+	casCmd := command("cas", "Compare-and-set updates (Cassandra LWT, conditional UPDATE on MySQL/TiDB)", workload.KindCAS)
+	ycsbECmd := ycsb("e", "short ranges: 95% scans, 5% inserts", workload.KindYCSBE)
+
+	workload.BindMixedFlags(mixedCmd.Flags(), cfg)
+	workload.BindScanFlags(mixedCmd.Flags(), cfg)
+	workload.BindScanFlags(rangeScanCmd.Flags(), cfg)
+	workload.BindScanFlags(oltpCmd.Flags(), cfg)
+	workload.BindBankFlags(bankCmd.Flags(), cfg)
+	workload.BindCASFlags(casCmd.Flags(), cfg)
+	workload.BindScanFlags(ycsbECmd.Flags(), cfg)
+
+	return []*cobra.Command{
+		command("read-only", "Point reads only", workload.KindReadOnly),
+		command("write-only", "Updates only", workload.KindWriteOnly),
+		mixedCmd,
+		rangeScanCmd,
+		command("index-lookup", "Reads by k through the secondary index (needs --k-index)", workload.KindIndexLookup),
+		oltpCmd,
+		bankCmd,
+		casCmd,
+		ycsb("a", "update heavy: 50% reads, 50% updates", workload.KindYCSBA),
+		ycsb("b", "read mostly: 95% reads, 5% updates", workload.KindYCSBB),
+		ycsb("c", "read only: 100% reads", workload.KindYCSBC),
+		ycsb("d", "read latest: 95% reads, 5% inserts", workload.KindYCSBD),
+		ycsbECmd,
+		ycsb("f", "read-modify-write: 50% reads, 50% read-modify-writes", workload.KindYCSBF),
+	}
+}
+
+// kindDefaults applies the key distribution a workload is defined with,
+// unless --key-dist was given.
+func kindDefaults(cmd *cobra.Command, cfg *config.Config, kind workload.Kind) {
+	if d := kind.DefaultKeyDist(); d != "" && !cmd.Flags().Changed("key-dist") {
+		cfg.KeyDist = d
+	}
+}
+
 func runWorkload(cmd *cobra.Command, cfg config.Config, kind workload.Kind) error {
 	if cfg.Time <= 0 {
 		cfg.Time = 30 * time.Second
 	}
+	kindDefaults(cmd, &cfg, kind)
+
+	sweep := cfg.SweepThreads != "" || cfg.SweepRates != ""
+	if sweep && cfg.Repeat > 1 {
+		return fmt.Errorf("repeat cannot be combined with a sweep")
+	}
+	if sweep {
+		return runSweep(cmd.Context(), cfg, kind)
+	}
+	if cfg.Repeat > 1 {
+		return runRepeat(cmd.Context(), cfg, kind)
+	}
 
 	ctx, cancel := context.WithTimeout(cmd.Context(), cfg.Timeout)
 	defer cancel()
@@ -119,3 +227,65 @@ func runWorkload(cmd *cobra.Command, cfg config.Config, kind workload.Kind) erro
 	}
 	return report.Print(cfg.Output, res)
 }
+
+// runRepeat runs every repeat on one connection; --timeout applies to
+// each repeat.
+func runRepeat(ctx context.Context, cfg config.Config, kind workload.Kind) error {
+	openCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	dbClient, err := db.Open(openCtx, cfg)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Close()
+
+	res, err := workload.Repeat(ctx, dbClient, cfg, kind)
+	if len(res.Runs) > 0 {
+		if perr := report.PrintRepeated(cfg.Output, res); perr != nil && err == nil {
+			err = perr
+		}
+	}
+	return err
+}
+
+// runSweep runs every sweep step on one connection; --timeout applies to
+// each step.
+func runSweep(ctx context.Context, cfg config.Config, kind workload.Kind) error {
+	openCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	dbClient, err := db.Open(openCtx, cfg)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Close()
+
+	res, err := workload.Sweep(ctx, dbClient, cfg, kind)
+	if len(res.Steps) > 0 {
+		if perr := report.PrintSweep(cfg.Output, res); perr != nil && err == nil {
+			err = perr
+		}
+	}
+	return err
+}
+
+// searchWorkload runs a max-throughput search on one connection;
+// --timeout applies to each trial.
+func searchWorkload(cmd *cobra.Command, cfg config.Config, kind workload.Kind) error {
+	kindDefaults(cmd, &cfg, kind)
+
+	openCtx, cancel := context.WithTimeout(cmd.Context(), cfg.Timeout)
+	dbClient, err := db.Open(openCtx, cfg)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer dbClient.Close()
+
+	res, err := workload.Search(cmd.Context(), dbClient, cfg, kind)
+	if len(res.Trials) > 0 {
+		if perr := report.PrintSearch(cfg.Output, res); perr != nil && err == nil {
+			err = perr
+		}
+	}
+	return err
+}
@@ -0,0 +1,214 @@
+// Package compare lines up saved run results and checks them against
+// regression thresholds.
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/metrics"
+)
+
+func BindFlags(fs *pflag.FlagSet, cfg *config.Config) {
+	fs.Float64Var(&cfg.CompareMaxQPSDrop, "max-qps-drop", cfg.CompareMaxQPSDrop, "Fail when QPS drops by more than this fraction of the baseline (0 = off)")
+	fs.Float64Var(&cfg.CompareMaxLatencyIncrease, "max-latency-increase", cfg.CompareMaxLatencyIncrease, "Fail when a --latency-metrics latency grows by more than this fraction of the baseline (0 = off)")
+	fs.Float64Var(&cfg.CompareAlpha, "alpha", cfg.CompareAlpha, "Significance level of the Welch t-test between two --repeat results; a change that is not significant is not a regression")
+	fs.BoolVar(&cfg.CompareAllowMismatch, "allow-mismatch", cfg.CompareAllowMismatch, "Compare results of different workloads or of open- and closed-loop runs instead of refusing")
+	fs.StringSliceVar(&cfg.CompareLatencyMetrics, "latency-metrics", cfg.CompareLatencyMetrics, "Latencies checked by --max-latency-increase: avg,p50,p95,p99,p999")
+}
+
+// metric is one compared value of a summary.
+type metric struct {
+	name           string
+	higherIsBetter bool
+	latency        bool
+	get            func(s metrics.Summary) float64
+}
+
+var compared = []metric{
+	{"qps", true, false, func(s metrics.Summary) float64 { return s.QPS }},
+	{"avg", false, true, func(s metrics.Summary) float64 { return s.AvgMs }},
+	{"p50", false, true, func(s metrics.Summary) float64 { return s.P50Ms }},
+	{"p95", false, true, func(s metrics.Summary) float64 { return s.P95Ms }},
+	{"p99", false, true, func(s metrics.Summary) float64 { return s.P99Ms }},
+	{"p999", false, true, func(s metrics.Summary) float64 { return s.P999Ms }},
+	{"errors", false, false, func(s metrics.Summary) float64 { return float64(s.Errors) }},
+}
+
+// Load reads a result written by run --output json: the summary of one
+// run, or the runs of a --repeat result.
+func Load(path string) ([]metrics.Summary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var repeated struct {
+		Runs []metrics.Summary `json:"runs"`
+	}
+	if err := json.Unmarshal(data, &repeated); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(repeated.Runs) > 0 {
+		return repeated.Runs, nil
+	}
+	var s metrics.Summary
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if s.Name == "" || s.Dur <= 0 {
+		return nil, fmt.Errorf("%s: not a run result", path)
+	}
+	return []metrics.Summary{s}, nil
+}
+
+// mismatches describes how result s differs from the baseline base in what
+// it measured. refuse lists the differences that make the numbers mean
+// different things: another workload, or open- against closed-loop
+// latencies, which are timed from the intended rather than the actual
+// start. notes lists those worth pointing out, such as the database, which
+// a comparison across databases changes on purpose.
+func mismatches(base, s metrics.Summary) (refuse, notes []string) {
+	baseKind, baseDB, _ := strings.Cut(base.Name, "/")
+	kind, db, _ := strings.Cut(s.Name, "/")
+	if kind != baseKind {
+		refuse = append(refuse, fmt.Sprintf("workload %s, baseline %s", kind, baseKind))
+	}
+	loop := func(s metrics.Summary) string {
+		if s.TargetRate > 0 {
+			return "open-loop"
+		}
+		return "closed-loop"
+	}
+	if loop(s) != loop(base) {
+		refuse = append(refuse, fmt.Sprintf("%s latencies, baseline %s", loop(s), loop(base)))
+	} else if s.TargetRate != base.TargetRate {
+		notes = append(notes, fmt.Sprintf("target rate %g, baseline %g", s.TargetRate, base.TargetRate))
+	}
+	if db != baseDB {
+		notes = append(notes, fmt.Sprintf("database %s, baseline %s", db, baseDB))
+	}
+	if s.KeyDist != base.KeyDist {
+		notes = append(notes, fmt.Sprintf("key distribution %s, baseline %s", s.KeyDist, base.KeyDist))
+	}
+	return refuse, notes
+}
+
+// Compare lines up the results in files against the first and records
+// every result past the thresholds in cfg, or with errors where the
+// baseline had none while a threshold is set, as a regression. Results of
+// --repeat compare by their means; when both sides have repeats, a change
+// past a threshold only counts if Welch's t-test finds it significant.
+// Results that measure something else than the baseline are refused
+// unless cfg.CompareAllowMismatch is set; every difference found is listed
+// in the comparison.
+func Compare(cfg config.Config, files []string) (metrics.Comparison, error) {
+	if len(files) < 2 {
+		return metrics.Comparison{}, fmt.Errorf("compare needs at least two results")
+	}
+	if cfg.CompareMaxQPSDrop < 0 || cfg.CompareMaxLatencyIncrease < 0 {
+		return metrics.Comparison{}, fmt.Errorf("max-qps-drop and max-latency-increase must be >= 0")
+	}
+	if cfg.CompareAlpha <= 0 || cfg.CompareAlpha >= 1 {
+		return metrics.Comparison{}, fmt.Errorf("alpha must be in (0, 1)")
+	}
+	gated := make(map[string]bool, len(cfg.CompareLatencyMetrics))
+	for _, name := range cfg.CompareLatencyMetrics {
+		found := false
+		for _, m := range compared {
+			found = found || (m.latency && m.name == name)
+		}
+		if !found {
+			return metrics.Comparison{}, fmt.Errorf("unsupported latency metric: %s", name)
+		}
+		gated[name] = true
+	}
+
+	// A result with errors where the baseline had none fails whenever any
+	// check is on.
+	checked := cfg.CompareMaxQPSDrop > 0 || cfg.CompareMaxLatencyIncrease > 0
+
+	results := make([][]metrics.Summary, len(files))
+	c := metrics.Comparison{Files: files, Alpha: cfg.CompareAlpha}
+	for i, f := range files {
+		runs, err := Load(f)
+		if err != nil {
+			return metrics.Comparison{}, err
+		}
+		results[i] = runs
+		c.Names = append(c.Names, runs[0].Name)
+		c.Runs = append(c.Runs, len(runs))
+		if i == 0 {
+			continue
+		}
+		refuse, notes := mismatches(results[0][0], runs[0])
+		if len(refuse) > 0 && !cfg.CompareAllowMismatch {
+			return metrics.Comparison{}, fmt.Errorf("%s does not measure the same as %s: %s (--allow-mismatch compares anyway)",
+				f, files[0], strings.Join(refuse, "; "))
+		}
+		for _, m := range append(refuse, notes...) {
+			c.Mismatches = append(c.Mismatches, fmt.Sprintf("%s: %s", f, m))
+		}
+	}
+
+	for _, m := range compared {
+		row := metrics.Row{Metric: m.name, HigherIsBetter: m.higherIsBetter}
+		samples := make([][]float64, len(results))
+		for i, runs := range results {
+			for _, s := range runs {
+				samples[i] = append(samples[i], m.get(s))
+			}
+		}
+		base := metrics.NewStat(samples[0]).Mean
+		tested := false
+		for i := range results {
+			v := metrics.NewStat(samples[i]).Mean
+			var welch *metrics.Welch
+			if i > 0 {
+				if w, ok := metrics.WelchTest(samples[0], samples[i]); ok {
+					welch, tested = &w, true
+				}
+			}
+			row.Welch = append(row.Welch, welch)
+			var pct float64
+			if base != 0 {
+				pct = 100 * (v - base) / base
+				row.DeltaPct = append(row.DeltaPct, &pct)
+			} else {
+				row.DeltaPct = append(row.DeltaPct, nil)
+			}
+			row.Values = append(row.Values, v)
+			row.Delta = append(row.Delta, v-base)
+
+			if i == 0 || (welch != nil && welch.P >= cfg.CompareAlpha) {
+				continue
+			}
+			significance := ""
+			if welch != nil {
+				significance = fmt.Sprintf(", p=%.3g", welch.P)
+			}
+			switch {
+			case m.name == "errors" && checked && base == 0 && v > 0:
+				// No fraction of 0 allows for any errors.
+				c.Regressions = append(c.Regressions, fmt.Sprintf("%s: errors %.0f, the baseline had none%s",
+					files[i], v, significance))
+			case base == 0:
+			case m.name == "qps" && cfg.CompareMaxQPSDrop > 0 && v < base*(1-cfg.CompareMaxQPSDrop):
+				c.Regressions = append(c.Regressions, fmt.Sprintf("%s: qps %.2f is %.1f%% below the baseline %.2f (limit %.1f%%%s)",
+					files[i], v, -pct, base, 100*cfg.CompareMaxQPSDrop, significance))
+			case gated[m.name] && cfg.CompareMaxLatencyIncrease > 0 && v > base*(1+cfg.CompareMaxLatencyIncrease):
+				c.Regressions = append(c.Regressions, fmt.Sprintf("%s: %s %.3fms is %.1f%% above the baseline %.3fms (limit %.1f%%%s)",
+					files[i], m.name, v, pct, base, 100*cfg.CompareMaxLatencyIncrease, significance))
+			}
+		}
+		if !tested {
+			row.Welch = nil
+		}
+		c.Rows = append(c.Rows, row)
+	}
+	return c, nil
+}
@@ -0,0 +1,192 @@
+package compare
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/metrics"
+)
+
+func TestMismatches(t *testing.T) {
+	base := metrics.Summary{Name: "mixed/mysql", KeyDist: "uniform"}
+	tests := []struct {
+		name          string
+		modify        func(s *metrics.Summary)
+		refuse, notes int
+	}{
+		{"same", func(s *metrics.Summary) {}, 0, 0},
+		{"other database", func(s *metrics.Summary) { s.Name = "mixed/tidb" }, 0, 1},
+		{"other workload", func(s *metrics.Summary) { s.Name = "ycsb-a/mysql" }, 1, 0},
+		{"open loop", func(s *metrics.Summary) { s.TargetRate = 1000 }, 1, 0},
+		{"other key distribution", func(s *metrics.Summary) { s.KeyDist = "zipfian" }, 0, 1},
+		{"everything", func(s *metrics.Summary) {
+			s.Name, s.TargetRate, s.KeyDist = "ycsb-a/cassandra", 1000, "zipfian"
+		}, 2, 2},
+	}
+	for _, tt := range tests {
+		s := base
+		tt.modify(&s)
+		refuse, notes := mismatches(base, s)
+		if len(refuse) != tt.refuse || len(notes) != tt.notes {
+			t.Errorf("%s: refuse %q, notes %q, want %d and %d", tt.name, refuse, notes, tt.refuse, tt.notes)
+		}
+	}
+
+	open := base
+	open.TargetRate = 1000
+	faster := open
+	faster.TargetRate = 2000
+	if refuse, notes := mismatches(open, faster); len(refuse) != 0 || len(notes) != 1 {
+		t.Errorf("open-loop runs at other rates: refuse %q, notes %q, want one note", refuse, notes)
+	}
+}
+
+func writeResult(t *testing.T, dir, name string, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCompare(t *testing.T) {
+	run := func(name string, qps, p99 float64) metrics.Summary {
+		return metrics.Summary{Name: name, Dur: time.Minute, Ops: int64(qps * 60), QPS: qps, P99Ms: p99}
+	}
+	withErrors := func(s metrics.Summary, errors int64) metrics.Summary {
+		s.Errors = errors
+		return s
+	}
+	tests := []struct {
+		name        string
+		base, other any
+		modify      func(cfg *config.Config)
+		regressions int
+		mismatches  int
+		wantErr     string
+	}{
+		{
+			name: "within limits",
+			base: run("mixed/mysql", 1000, 10), other: run("mixed/mysql", 980, 10.5),
+			modify: func(cfg *config.Config) { cfg.CompareMaxQPSDrop, cfg.CompareMaxLatencyIncrease = 0.05, 0.1 },
+		},
+		{
+			name: "qps drop",
+			base: run("mixed/mysql", 1000, 10), other: run("mixed/mysql", 900, 10),
+			modify:      func(cfg *config.Config) { cfg.CompareMaxQPSDrop = 0.05 },
+			regressions: 1,
+		},
+		{
+			name: "p99 increase",
+			base: run("mixed/mysql", 1000, 10), other: run("mixed/mysql", 1000, 12),
+			modify:      func(cfg *config.Config) { cfg.CompareMaxLatencyIncrease = 0.1 },
+			regressions: 1,
+		},
+		{
+			name: "errors where the baseline had none",
+			base: run("mixed/mysql", 1000, 10), other: withErrors(run("mixed/mysql", 1000, 10), 12),
+			modify:      func(cfg *config.Config) { cfg.CompareMaxQPSDrop = 0.05 },
+			regressions: 1,
+		},
+		{
+			name: "errors without checks",
+			base: run("mixed/mysql", 1000, 10), other: withErrors(run("mixed/mysql", 1000, 10), 12),
+		},
+		{
+			name: "across databases",
+			base: run("mixed/mysql", 1000, 10), other: run("mixed/tidb", 1000, 10),
+			mismatches: 1,
+		},
+		{
+			name: "other workload refused",
+			base: run("mixed/mysql", 1000, 10), other: run("read-only/mysql", 1000, 10),
+			wantErr: "workload read-only",
+		},
+		{
+			name: "other workload allowed",
+			base: run("mixed/mysql", 1000, 10), other: run("read-only/mysql", 1000, 10),
+			modify:     func(cfg *config.Config) { cfg.CompareAllowMismatch = true },
+			mismatches: 1,
+		},
+		{
+			name: "noise in repeats is not a regression",
+			base: metrics.Repeated{Runs: []metrics.Summary{
+				run("mixed/mysql", 1000, 10), run("mixed/mysql", 1200, 10), run("mixed/mysql", 800, 10),
+			}},
+			other: metrics.Repeated{Runs: []metrics.Summary{
+				run("mixed/mysql", 900, 10), run("mixed/mysql", 1100, 10), run("mixed/mysql", 700, 10),
+			}},
+			modify: func(cfg *config.Config) { cfg.CompareMaxQPSDrop = 0.05 },
+		},
+		{
+			name: "significant drop in repeats",
+			base: metrics.Repeated{Runs: []metrics.Summary{
+				run("mixed/mysql", 1000, 10), run("mixed/mysql", 1010, 10), run("mixed/mysql", 990, 10),
+			}},
+			other: metrics.Repeated{Runs: []metrics.Summary{
+				run("mixed/mysql", 800, 10), run("mixed/mysql", 810, 10), run("mixed/mysql", 790, 10),
+			}},
+			modify:      func(cfg *config.Config) { cfg.CompareMaxQPSDrop = 0.05 },
+			regressions: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			files := []string{writeResult(t, dir, "base.json", tt.base), writeResult(t, dir, "other.json", tt.other)}
+			cfg := config.Default()
+			if tt.modify != nil {
+				tt.modify(&cfg)
+			}
+			c, err := Compare(cfg, files)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(c.Regressions) != tt.regressions {
+				t.Errorf("regressions %q, want %d", c.Regressions, tt.regressions)
+			}
+			if len(c.Mismatches) != tt.mismatches {
+				t.Errorf("mismatches %q, want %d", c.Mismatches, tt.mismatches)
+			}
+			for _, row := range c.Rows {
+				if (row.Values[0] == 0) != (row.DeltaPct[1] == nil) {
+					t.Errorf("%s: baseline %g, change %v; want a percentage exactly when the baseline is not 0",
+						row.Metric, row.Values[0], row.DeltaPct[1])
+				}
+			}
+		})
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"empty.json":   `{}`,
+		"sweep.json":   `{"param": "threads", "steps": []}`,
+		"invalid.json": `{"name":`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("Load(%s) succeeded, want an error", name)
+		}
+	}
+}
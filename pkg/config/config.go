@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/spf13/pflag"
+
+	"tidb-benchmarks/pkg/metrics"
 )
 
 type DBKind string
@@ -11,6 +13,7 @@ type DBKind string
 const (
 	DBMySQL     DBKind = "mysql"
 	DBCassandra DBKind = "cassandra"
+	DBTiDB      DBKind = "tidb"
 )
 
 type OutputFormat string
@@ -20,11 +23,38 @@ const (
 	OutputJSON OutputFormat = "json"
 )
 
+type KeyDist string
+
+const (
+	KeyDistUniform    KeyDist = "uniform"
+	KeyDistZipfian    KeyDist = "zipfian"
+	KeyDistScrambled  KeyDist = "scrambled-zipfian"
+	KeyDistHotspot    KeyDist = "hotspot"
+	KeyDistLatest     KeyDist = "latest"
+	KeyDistSequential KeyDist = "sequential"
+)
+
 type Config struct {
 	DB DBKind
 
-	MySQLDSN string
-	MySQLTLS bool
+	MySQLDSN      string
+	MySQLTLS      bool
+	MySQLPrepared string
+	MySQLLoadData bool
+
+	// TiDB table options, applied by the tidb backend on top of MySQLDSN.
+	TiDBClusteredIndex  string
+	TiDBAutoRandomBits  int
+	TiDBShardRowIDBits  int
+	TiDBPreSplitRegions int
+	TiDBSplitRegions    int
+
+	// TiDB session settings, applied to every connection.
+	TiDBTxnMode     string
+	TiDBAsyncCommit string
+	TiDB1PC         string
+	TiDBReplicaRead string
+	TiDBStaleRead   time.Duration
 
 	CassandraHosts         string
 	CassandraKeyspace      string
@@ -32,55 +62,168 @@ type Config struct {
 	CassandraPassword      string
 	CassandraConsistency   string
 	CassandraTLSSkipVerify bool
+	CassandraPartitionRows int64
 
 	Table       string
+	Tables      int
+	KIndex      string
 	TableSize   int64
 	PayloadSize int
+	BatchSize   int
+
+	ChunkSize  int64
+	Checkpoint string
+	Resume     bool
+	IDRange    string
 
 	Threads int
+	Rate    float64
 	Time    time.Duration
 	Timeout time.Duration
 
-	ReadRatio float64
+	ReadRatio   float64
+	ScanRatio   float64
+	InsertRatio float64
+	DeleteRatio float64
+
+	CountDeletedReads bool
+
+	KeyDist            KeyDist
+	ZipfianTheta       float64
+	HotspotFraction    float64
+	HotspotProbability float64
+
+	ScanLength  int
+	InsertStart int64
+
+	Verify bool
+
+	// Bank workload.
+	BankAccounts      int64
+	BankBalance       int64
+	BankMode          string
+	BankCheckInterval time.Duration
+
+	// Compare-and-set workload.
+	CASKeys    int64
+	CASRetries int
 
 	Warmup time.Duration
 
+	ReportInterval time.Duration
+	// OnInterval, if set, is called with every interval report of a run,
+	// the last one cut short when the run ends. It is not a flag; the
+	// command sets it to print the reports.
+	OnInterval func(metrics.Interval)
+
+	MaxErrors    int64
+	MaxErrorRate float64
+
+	// Retries of failed operations; RetryAttempts 1 turns them off.
+	RetryAttempts   int
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	RetryOn         []string
+
+	// Repeated runs; see workload.Repeat.
+	Repeat         int
+	RepeatCooldown time.Duration
+
+	// Sweeps over thread counts or rates; see workload.Sweep.
+	SweepThreads string
+	SweepRates   string
+	SweepP99Max  time.Duration
+	SweepMinGain float64
+
+	// Max-throughput search; see workload.Search.
+	SearchParam     string
+	SearchMin       float64
+	SearchMax       float64
+	SearchPrecision float64
+	SearchTrialTime time.Duration
+	SLOP99          time.Duration
+
+	// Regression thresholds of bench compare; 0 turns a check off.
+	CompareMaxQPSDrop         float64
+	CompareMaxLatencyIncrease float64
+	CompareLatencyMetrics     []string
+	CompareAlpha              float64
+	CompareAllowMismatch      bool
+
 	Output OutputFormat
 }
 
 func Default() Config {
 	return Config{
-		DB:                   DBMySQL,
-		MySQLDSN:             "root:@tcp(127.0.0.1:3306)/test?parseTime=true&multiStatements=true",
-		MySQLTLS:             true,
-		CassandraHosts:       "127.0.0.1",
-		CassandraKeyspace:    "bench",
-		CassandraConsistency: "LOCAL_QUORUM",
-		Table:                "sbtest",
-		TableSize:            100000,
-		PayloadSize:          120,
-		Threads:              16,
-		Time:                 30 * time.Second,
-		Timeout:              10 * time.Minute,
-		ReadRatio:            0.5,
-		Warmup:               2 * time.Second,
-		Output:               OutputText,
+		DB:                    DBMySQL,
+		MySQLDSN:              "root:@tcp(127.0.0.1:3306)/test?parseTime=true&multiStatements=true",
+		MySQLTLS:              true,
+		MySQLPrepared:         "server",
+		CassandraHosts:        "127.0.0.1",
+		CassandraKeyspace:     "bench",
+		CassandraConsistency:  "LOCAL_QUORUM",
+		Table:                 "sbtest",
+		TableSize:             100000,
+		PayloadSize:           120,
+		BatchSize:             1,
+		Threads:               16,
+		Time:                  30 * time.Second,
+		Timeout:               10 * time.Minute,
+		ReadRatio:             0.5,
+		KeyDist:               KeyDistUniform,
+		ZipfianTheta:          0.99,
+		HotspotFraction:       0.2,
+		HotspotProbability:    0.8,
+		ScanLength:            100,
+		BankAccounts:          1000,
+		BankBalance:           1000,
+		BankCheckInterval:     time.Second,
+		CASKeys:               1000,
+		CASRetries:            3,
+		Warmup:                2 * time.Second,
+		RetryAttempts:         1,
+		RetryBackoff:          10 * time.Millisecond,
+		RetryMaxBackoff:       time.Second,
+		Repeat:                1,
+		SweepMinGain:          0.05,
+		SearchParam:           "rate",
+		SearchMin:             100,
+		SearchPrecision:       0.05,
+		SearchTrialTime:       10 * time.Second,
+		CompareLatencyMetrics: []string{"p99"},
+		CompareAlpha:          0.05,
+		Output:                OutputText,
 	}
 }
 
 func BindCommonFlags(fs *pflag.FlagSet, cfg *Config) {
-	fs.StringVar((*string)(&cfg.DB), "db", string(cfg.DB), "Target database: mysql|cassandra")
-	fs.StringVar(&cfg.MySQLDSN, "mysql-dsn", cfg.MySQLDSN, "MySQL DSN")
+	fs.StringVar((*string)(&cfg.DB), "db", string(cfg.DB), "Target database: mysql|tidb|cassandra")
+	fs.StringVar(&cfg.MySQLDSN, "mysql-dsn", cfg.MySQLDSN, "MySQL DSN (also used for TiDB)")
 	fs.BoolVar(&cfg.MySQLTLS, "mysql-tls", cfg.MySQLTLS, "Enable TLS for MySQL")
+	fs.StringVar(&cfg.MySQLPrepared, "mysql-prepared", cfg.MySQLPrepared, "MySQL/TiDB statement mode: server (prepared once, binary protocol) | client (driver interpolation, text protocol) | per-call (driver prepares, executes and closes each statement)")
+	fs.StringVar(&cfg.TiDBClusteredIndex, "tidb-clustered-index", cfg.TiDBClusteredIndex, "TiDB primary key layout: clustered|nonclustered (empty = server default)")
+	fs.IntVar(&cfg.TiDBAutoRandomBits, "tidb-auto-random", cfg.TiDBAutoRandomBits, "TiDB AUTO_RANDOM shard bits for the id column (0 = off, requires clustered)")
+	fs.IntVar(&cfg.TiDBShardRowIDBits, "tidb-shard-row-id-bits", cfg.TiDBShardRowIDBits, "TiDB SHARD_ROW_ID_BITS table option (requires nonclustered)")
+	fs.IntVar(&cfg.TiDBPreSplitRegions, "tidb-pre-split-regions", cfg.TiDBPreSplitRegions, "TiDB PRE_SPLIT_REGIONS table option (requires shard-row-id-bits)")
+	fs.IntVar(&cfg.TiDBSplitRegions, "tidb-split-regions", cfg.TiDBSplitRegions, "Split the table into this many regions when setting it up and after truncating, over the id range or all handles when sharded (0 = off)")
+	fs.StringVar(&cfg.TiDBTxnMode, "tidb-txn-mode", cfg.TiDBTxnMode, "TiDB transaction mode: optimistic|pessimistic (empty = server default)")
+	fs.StringVar(&cfg.TiDBAsyncCommit, "tidb-async-commit", cfg.TiDBAsyncCommit, "TiDB async commit: on|off (empty = server default)")
+	fs.StringVar(&cfg.TiDB1PC, "tidb-1pc", cfg.TiDB1PC, "TiDB one-phase commit: on|off (empty = server default)")
+	fs.StringVar(&cfg.TiDBReplicaRead, "tidb-replica-read", cfg.TiDBReplicaRead, "TiDB replica read: leader|follower|leader-and-follower (empty = server default)")
+	fs.DurationVar(&cfg.TiDBStaleRead, "tidb-stale-read", cfg.TiDBStaleRead, "Read with AS OF TIMESTAMP this far in the past (0 = off)")
+	fs.BoolVar(&cfg.MySQLLoadData, "mysql-load-data", cfg.MySQLLoadData, "Bulk-load batches with LOAD DATA LOCAL INFILE instead of multi-row INSERT (server needs local_infile=1)")
 	fs.StringVar(&cfg.CassandraHosts, "cassandra-hosts", cfg.CassandraHosts, "Cassandra hosts, comma-separated")
 	fs.StringVar(&cfg.CassandraKeyspace, "cassandra-keyspace", cfg.CassandraKeyspace, "Cassandra keyspace")
 	fs.StringVar(&cfg.CassandraUsername, "cassandra-username", cfg.CassandraUsername, "Cassandra username")
 	fs.StringVar(&cfg.CassandraPassword, "cassandra-password", cfg.CassandraPassword, "Cassandra password")
 	fs.StringVar(&cfg.CassandraConsistency, "cassandra-consistency", cfg.CassandraConsistency, "Cassandra consistency (e.g. ONE, LOCAL_QUORUM)")
 	fs.BoolVar(&cfg.CassandraTLSSkipVerify, "cassandra-tls-skip-verify", cfg.CassandraTLSSkipVerify, "Skip TLS certificate/hostname verification for Cassandra (INSECURE)")
+	fs.Int64Var(&cfg.CassandraPartitionRows, "cassandra-partition-rows", cfg.CassandraPartitionRows, "Store this many consecutive ids per Cassandra partition, clustered by id, so scans are clustering-order slices like the MySQL id range (0 = one row per partition, scans in token order)")
 
 	fs.StringVar(&cfg.Table, "table", cfg.Table, "Target table name")
-	fs.Int64Var(&cfg.TableSize, "table-size", cfg.TableSize, "Number of rows")
+	fs.IntVar(&cfg.Tables, "tables", cfg.Tables, "Spread load over this many tables named <table>1..N (0 = the single table --table)")
+	fs.StringVar(&cfg.KIndex, "k-index", cfg.KIndex, "Secondary index on the k column: on (MySQL/TiDB index, Cassandra secondary index) | sai (Cassandra storage-attached index) | empty for none")
+	fs.Int64Var(&cfg.TableSize, "table-size", cfg.TableSize, "Number of rows per table")
 	fs.IntVar(&cfg.PayloadSize, "payload-size", cfg.PayloadSize, "Payload size in bytes")
 
 	fs.IntVar(&cfg.Threads, "threads", cfg.Threads, "Number of concurrent workers")
@@ -0,0 +1,105 @@
+package cassandra
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gocql/gocql"
+
+	"tidb-benchmarks/pkg/config"
+)
+
+// Bank modes selected by --bank-mode.
+const (
+	// BankLWT debits and credits with two lightweight transactions, each
+	// retried on contention. Each side is linearizable, but the pair is
+	// not atomic: a credit that never applies loses the debited amount.
+	BankLWT = "lwt"
+	// BankBatch reads both balances and writes the new values in one
+	// LOGGED batch. The writes apply together, but concurrent transfers
+	// read stale balances and overwrite each other.
+	BankBatch = "batch"
+)
+
+// casAttempts bounds how often one side of an LWT transfer is retried
+// after losing a race.
+const casAttempts = 10
+
+// serialRead reads at SERIAL consistency, which completes any in-flight
+// lightweight transaction first. gocql only names SERIAL as a serial
+// consistency, but the protocol takes the same value for reads.
+const serialRead = gocql.Consistency(gocql.Serial)
+
+// errCASExhausted reports an LWT that lost every attempt.
+var errCASExhausted = errors.New("lightweight transaction not applied after retries")
+
+// Transfer moves amount from one account's balance in k to another's;
+// see BankLWT and BankBatch for what each mode guarantees.
+func (c *Client) Transfer(ctx context.Context, cfg config.Config, from, to, amount int64) error {
+	if from == to {
+		return nil
+	}
+	q := c.queries(cfg)
+	if c.bankMode == BankBatch {
+		fromBal, err := c.readK(ctx, q, from, c.consistency)
+		if err != nil {
+			return err
+		}
+		toBal, err := c.readK(ctx, q, to, c.consistency)
+		if err != nil {
+			return err
+		}
+		b := c.session.NewBatch(gocql.LoggedBatch).WithContext(ctx)
+		b.Query(q.updateK, append([]any{fromBal - amount}, c.key(from)...)...)
+		b.Query(q.updateK, append([]any{toBal + amount}, c.key(to)...)...)
+		return c.session.ExecuteBatch(b)
+	}
+
+	if err := c.addCAS(ctx, q, from, -amount); err != nil {
+		return err
+	}
+	if err := c.addCAS(ctx, q, to, amount); err != nil {
+		return fmt.Errorf("credit of %d to %d failed after debit: %w", amount, to, err)
+	}
+	return nil
+}
+
+func (c *Client) readK(ctx context.Context, q *tableQueries, id int64, cl gocql.Consistency) (int64, error) {
+	var k int64
+	err := c.session.Query(q.readK, c.key(id)...).WithContext(ctx).Consistency(cl).Scan(&k)
+	return k, err
+}
+
+// addCAS adds delta to the balance of id with a conditional update,
+// starting from a serial read and retrying from the value the failed
+// update returns.
+func (c *Client) addCAS(ctx context.Context, q *tableQueries, id, delta int64) error {
+	cur, err := c.readK(ctx, q, id, serialRead)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; attempt < casAttempts; attempt++ {
+		args := append(append([]any{cur + delta}, c.key(id)...), cur)
+		applied, err := c.session.Query(q.updateKIf, args...).WithContext(ctx).SerialConsistency(gocql.Serial).ScanCAS(&cur)
+		if err != nil {
+			return err
+		}
+		if applied {
+			return nil
+		}
+	}
+	return errCASExhausted
+}
+
+// Total sums every balance with a full table read. Cassandra has no
+// snapshot reads, so the sum is only exact while no transfer runs.
+func (c *Client) Total(ctx context.Context, cfg config.Config) (int64, int64, error) {
+	iter := c.session.Query(c.queries(cfg).totalK).WithContext(ctx).Consistency(c.consistency).Iter()
+	var k, sum, rows int64
+	for iter.Scan(&k) {
+		sum += k
+		rows++
+	}
+	return sum, rows, iter.Close()
+}
@@ -0,0 +1,31 @@
+package cassandra
+
+import (
+	"context"
+
+	"github.com/gocql/gocql"
+
+	"tidb-benchmarks/pkg/config"
+)
+
+// ReadK returns the k of id at SERIAL consistency, which completes any
+// in-flight lightweight transaction on it first.
+func (c *Client) ReadK(ctx context.Context, cfg config.Config, id int64) (int64, error) {
+	return c.readK(ctx, c.queries(cfg), id, serialRead)
+}
+
+// CompareAndSet sets k to next and c to payload with a lightweight
+// transaction, UPDATE ... IF k = ?. A failed one returns the current k
+// from the same Paxos round.
+func (c *Client) CompareAndSet(ctx context.Context, cfg config.Config, id, old, next int64, payload []byte) (bool, int64, error) {
+	args := append(append([]any{next, payload}, c.key(id)...), old)
+	var cur int64
+	applied, err := c.session.Query(c.queries(cfg).cas, args...).WithContext(ctx).SerialConsistency(gocql.Serial).ScanCAS(&cur)
+	if err != nil {
+		return false, 0, err
+	}
+	if applied {
+		cur = next
+	}
+	return applied, cur, nil
+}
@@ -3,20 +3,128 @@ package cassandra
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gocql/gocql"
 
 	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/db/dberr"
 )
 
 type Client struct {
-	session *gocql.Session
+	session     *gocql.Session
+	consistency gocql.Consistency
+
+	// bankMode is how Transfer moves balances: BankLWT or BankBatch.
+	bankMode string
+
+	// partitionRows is the number of consecutive ids stored per partition,
+	// clustered by id; 0 stores every row in its own partition.
+	partitionRows int64
+
+	// tables maps "keyspace.table" to its *tableQueries.
+	tables sync.Map
+}
+
+// tableQueries caches the CQL text for one table. gocql prepares every
+// query with bind markers on first use and reuses the prepared id, so
+// keeping the text stable is all that is needed to stay prepared. The
+// key columns depend on the layout; key returns their values.
+type tableQueries struct {
+	insert      string
+	read        string
+	update      string
+	scan        string
+	readByK     string
+	delete      string
+	deleteRange string
+
+	// Writes inside a Tx batch, with explicit timestamps that keep the
+	// statements ordered.
+	txInsert  string
+	txDelete  string
+	txUpdateK string
+	txUpdateC string
+
+	// Bank transfers keep balances in k.
+	readK     string
+	updateK   string
+	updateKIf string
+	totalK    string
+
+	// Compare-and-set keeps a version in k.
+	cas string
+}
+
+func (c *Client) queries(cfg config.Config) *tableQueries {
+	name := cfg.CassandraKeyspace + "." + cfg.Table
+	if v, ok := c.tables.Load(name); ok {
+		return v.(*tableQueries)
+	}
+	var q *tableQueries
+	if c.partitionRows > 0 {
+		q = &tableQueries{
+			insert:      fmt.Sprintf("INSERT INTO %s (bucket, id, k, c) VALUES (?, ?, ?, ?)", name),
+			read:        fmt.Sprintf("SELECT id, k, c FROM %s WHERE bucket = ? AND id = ?", name),
+			update:      fmt.Sprintf("UPDATE %s SET k = ?, c = ? WHERE bucket = ? AND id = ?", name),
+			scan:        fmt.Sprintf("SELECT id, k, c FROM %s WHERE bucket = ? AND id >= ? AND id <= ?", name),
+			readByK:     fmt.Sprintf("SELECT id, k, c FROM %s WHERE k = ?", name),
+			delete:      fmt.Sprintf("DELETE FROM %s WHERE bucket = ? AND id = ?", name),
+			deleteRange: fmt.Sprintf("DELETE FROM %s WHERE bucket = ? AND id >= ? AND id <= ?", name),
+		}
+	} else {
+		q = &tableQueries{
+			insert:  fmt.Sprintf("INSERT INTO %s (id, k, c) VALUES (?, ?, ?)", name),
+			read:    fmt.Sprintf("SELECT id, k, c FROM %s WHERE id = ?", name),
+			update:  fmt.Sprintf("UPDATE %s SET k = ?, c = ? WHERE id = ?", name),
+			scan:    fmt.Sprintf("SELECT id, k, c FROM %s WHERE token(id) >= token(?) LIMIT ?", name),
+			readByK: fmt.Sprintf("SELECT id, k, c FROM %s WHERE k = ?", name),
+			delete:  fmt.Sprintf("DELETE FROM %s WHERE id = ?", name),
+		}
+	}
+	cols, where := "id", "id = ?"
+	if c.partitionRows > 0 {
+		cols, where = "bucket, id", "bucket = ? AND id = ?"
+	}
+	marks := strings.Repeat("?, ", strings.Count(cols, ",")+1)
+	q.txInsert = fmt.Sprintf("INSERT INTO %s (%s, k, c) VALUES (%s?, ?) USING TIMESTAMP ?", name, cols, marks)
+	q.txDelete = fmt.Sprintf("DELETE FROM %s USING TIMESTAMP ? WHERE %s", name, where)
+	q.txUpdateK = fmt.Sprintf("UPDATE %s USING TIMESTAMP ? SET k = ? WHERE %s", name, where)
+	q.txUpdateC = fmt.Sprintf("UPDATE %s USING TIMESTAMP ? SET c = ? WHERE %s", name, where)
+	q.readK = fmt.Sprintf("SELECT k FROM %s WHERE %s", name, where)
+	q.updateK = fmt.Sprintf("UPDATE %s SET k = ? WHERE %s", name, where)
+	q.updateKIf = fmt.Sprintf("UPDATE %s SET k = ? WHERE %s IF k = ?", name, where)
+	q.totalK = fmt.Sprintf("SELECT k FROM %s", name)
+	q.cas = fmt.Sprintf("UPDATE %s SET k = ?, c = ? WHERE %s IF k = ?", name, where)
+	v, _ := c.tables.LoadOrStore(name, q)
+	return v.(*tableQueries)
+}
+
+// key returns the primary key values of id in bind order.
+func (c *Client) key(id int64) []any {
+	if c.partitionRows > 0 {
+		return []any{c.partition(id), id}
+	}
+	return []any{id}
 }
 
 func Open(ctx context.Context, cfg config.Config) (*Client, error) {
+	if cfg.CassandraPartitionRows < 0 {
+		return nil, fmt.Errorf("cassandra-partition-rows must be >= 0")
+	}
+	bankMode := cfg.BankMode
+	switch bankMode {
+	case BankLWT, BankBatch:
+	case "":
+		bankMode = BankLWT
+	default:
+		return nil, fmt.Errorf("bank-mode must be lwt or batch for Cassandra, got %q", cfg.BankMode)
+	}
 	base := gocql.NewCluster(splitHosts(cfg.CassandraHosts)...)
 	base.Timeout = 10 * time.Second
 	base.ConnectTimeout = 10 * time.Second
@@ -69,21 +177,65 @@ func Open(ctx context.Context, cfg config.Config) (*Client, error) {
 	default:
 	}
 
-	return &Client{session: sess}, nil
+	return &Client{session: sess, consistency: base.Consistency, partitionRows: cfg.CassandraPartitionRows, bankMode: bankMode}, nil
 }
 
 func (c *Client) Name() string { return "cassandra" }
 
 func (c *Client) PrepareSchema(ctx context.Context, cfg config.Config) error {
+	key := "PRIMARY KEY (id)"
+	bucket := ""
+	if c.partitionRows > 0 {
+		// Consecutive ids share a partition in id order, so a scan is a
+		// slice of one partition.
+		key = "PRIMARY KEY ((bucket), id)"
+		bucket = "\n\tbucket bigint,"
+	}
 	q := fmt.Sprintf(`
-CREATE TABLE IF NOT EXISTS %s.%s (
+CREATE TABLE IF NOT EXISTS %s.%s (%s
 	id bigint,
 	k bigint,
 	c blob,
-	PRIMARY KEY (id)
+	%s
 );
-`, cfg.CassandraKeyspace, cfg.Table)
-	return c.session.Query(q).WithContext(ctx).Exec()
+`, cfg.CassandraKeyspace, cfg.Table, bucket, key)
+	if err := c.session.Query(q).WithContext(ctx).Exec(); err != nil {
+		return err
+	}
+	if err := c.checkLayout(ctx, cfg); err != nil {
+		return err
+	}
+
+	var idx string
+	switch cfg.KIndex {
+	case "":
+		return nil
+	case "on":
+		idx = "CREATE INDEX IF NOT EXISTS %s_k_idx ON %s.%s (k)"
+	case "sai":
+		idx = "CREATE CUSTOM INDEX IF NOT EXISTS %s_k_idx ON %s.%s (k) USING 'StorageAttachedIndex'"
+	default:
+		return fmt.Errorf("k-index must be on or sai for Cassandra, got %q", cfg.KIndex)
+	}
+	return c.session.Query(fmt.Sprintf(idx, cfg.Table, cfg.CassandraKeyspace, cfg.Table)).WithContext(ctx).Exec()
+}
+
+// checkLayout fails when a table created earlier has the other layout:
+// CREATE TABLE IF NOT EXISTS keeps it, and every query would then name a
+// bucket column it lacks, or miss one it needs.
+func (c *Client) checkLayout(ctx context.Context, cfg config.Config) error {
+	var n int
+	q := "SELECT COUNT(*) FROM system_schema.columns WHERE keyspace_name = ? AND table_name = ? AND column_name = 'bucket'"
+	if err := c.session.Query(q, cfg.CassandraKeyspace, cfg.Table).WithContext(ctx).Scan(&n); err != nil {
+		return err
+	}
+	switch {
+	case c.partitionRows > 0 && n == 0:
+		return fmt.Errorf("table %s.%s has one row per partition; drop it or run without --cassandra-partition-rows", cfg.CassandraKeyspace, cfg.Table)
+	case c.partitionRows == 0 && n > 0:
+		return fmt.Errorf("table %s.%s is partitioned by bucket; drop it or set --cassandra-partition-rows to the value it was prepared with", cfg.CassandraKeyspace, cfg.Table)
+	}
+	return nil
 }
 
 func (c *Client) Truncate(ctx context.Context, cfg config.Config) error {
@@ -91,27 +243,95 @@ func (c *Client) Truncate(ctx context.Context, cfg config.Config) error {
 	return c.session.Query(q).WithContext(ctx).Exec()
 }
 
+// MaxID returns 0: finding the highest id takes a full scan, and inserts
+// are upserts that cannot fail on a row an earlier run inserted.
+func (c *Client) MaxID(ctx context.Context, cfg config.Config) (int64, error) {
+	return 0, nil
+}
+
 func (c *Client) Insert(ctx context.Context, cfg config.Config, id int64, k int64, payload []byte) error {
-	q := fmt.Sprintf("INSERT INTO %s.%s (id, k, c) VALUES (?, ?, ?)", cfg.CassandraKeyspace, cfg.Table)
-	return c.session.Query(q, id, k, payload).WithContext(ctx).Exec()
+	return c.session.Query(c.queries(cfg).insert, append(c.key(id), k, payload)...).WithContext(ctx).Exec()
 }
 
 func (c *Client) Read(ctx context.Context, cfg config.Config, id int64) ([]byte, error) {
-	q := fmt.Sprintf("SELECT id, k, c FROM %s.%s WHERE id = ?", cfg.CassandraKeyspace, cfg.Table)
 	var (
 		ignoredID int64
 		ignoredK  int64
 		payload   []byte
 	)
-	if err := c.session.Query(q, id).WithContext(ctx).Consistency(parseConsistency(cfg.CassandraConsistency)).Scan(&ignoredID, &ignoredK, &payload); err != nil {
+	if err := c.session.Query(c.queries(cfg).read, c.key(id)...).WithContext(ctx).Consistency(c.consistency).Scan(&ignoredID, &ignoredK, &payload); err != nil {
 		return nil, err
 	}
 	return payload, nil
 }
 
 func (c *Client) Update(ctx context.Context, cfg config.Config, id int64, k int64, payload []byte) error {
-	q := fmt.Sprintf("UPDATE %s.%s SET k = ?, c = ? WHERE id = ?", cfg.CassandraKeyspace, cfg.Table)
-	return c.session.Query(q, k, payload, id).WithContext(ctx).Exec()
+	return c.session.Query(c.queries(cfg).update, append([]any{k, payload}, c.key(id)...)...).WithContext(ctx).Exec()
+}
+
+// Delete writes a row tombstone; the space is reclaimed by compaction
+// after gc_grace_seconds.
+func (c *Client) Delete(ctx context.Context, cfg config.Config, id int64) error {
+	return c.session.Query(c.queries(cfg).delete, c.key(id)...).WithContext(ctx).Exec()
+}
+
+// Scan reads ids startID..startID+limit-1 in clustering order, like the
+// MySQL BETWEEN scan, with one slice per partition the range spans. With
+// --cassandra-partition-rows 0 it follows the YCSB Cassandra binding
+// instead: limit rows in token order from the token of startID.
+func (c *Client) Scan(ctx context.Context, cfg config.Config, startID int64, limit int) (rows, n int, err error) {
+	err = c.scanRows(ctx, c.queries(cfg), startID, limit, func(_ int64, payload []byte) {
+		rows++
+		n += len(payload)
+	})
+	return rows, n, err
+}
+
+// scanRows runs the scan of limit ids from startID and visits the k and
+// payload of every row it returns.
+func (c *Client) scanRows(ctx context.Context, q *tableQueries, startID int64, limit int, visit func(k int64, payload []byte)) error {
+	var (
+		ignoredID int64
+		k         int64
+		payload   []byte
+	)
+	if c.partitionRows == 0 {
+		iter := c.session.Query(q.scan, startID, limit).WithContext(ctx).Consistency(c.consistency).Iter()
+		for iter.Scan(&ignoredID, &k, &payload) {
+			visit(k, payload)
+		}
+		return iter.Close()
+	}
+	end := startID + int64(limit) - 1
+	for lo := startID; lo <= end; {
+		part := c.partition(lo)
+		hi := min((part+1)*c.partitionRows, end)
+		iter := c.session.Query(q.scan, part, lo, hi).WithContext(ctx).Consistency(c.consistency).Iter()
+		for iter.Scan(&ignoredID, &k, &payload) {
+			visit(k, payload)
+		}
+		if err := iter.Close(); err != nil {
+			return err
+		}
+		lo = hi + 1
+	}
+	return nil
+}
+
+// ReadByK queries the secondary index on k. The index is local to each
+// node, so the coordinator may have to ask every token range.
+func (c *Client) ReadByK(ctx context.Context, cfg config.Config, k int64) (rows, n int, err error) {
+	iter := c.session.Query(c.queries(cfg).readByK, k).WithContext(ctx).Consistency(c.consistency).Iter()
+	var (
+		ignoredID int64
+		ignoredK  int64
+		payload   []byte
+	)
+	for iter.Scan(&ignoredID, &ignoredK, &payload) {
+		rows++
+		n += len(payload)
+	}
+	return rows, n, iter.Close()
 }
 
 func (c *Client) Close() error {
@@ -119,6 +339,55 @@ func (c *Client) Close() error {
 	return nil
 }
 
+// ClassifyError maps gocql errors to a dberr.Class. A lightweight
+// transaction that timed out, even under Paxos contention, may still have
+// applied, so it counts as a timeout, which is never retried for writes
+// that are not idempotent, rather than as a lost race. Only one that lost
+// every attempt is a conflict.
+func (c *Client) ClassifyError(err error) dberr.Class {
+	if class, ok := dberr.Classify(err); ok {
+		return class
+	}
+	var (
+		writeTimeout *gocql.RequestErrWriteTimeout
+		casUnknown   *gocql.RequestErrCASWriteUnknown
+		reqErr       gocql.RequestError
+	)
+	switch {
+	case errors.As(err, &writeTimeout), errors.As(err, &casUnknown):
+		return dberr.ClassTimeout
+	case errors.Is(err, errCASExhausted):
+		return dberr.ClassConflict
+	case errors.Is(err, gocql.ErrNotFound):
+		return dberr.ClassNotFound
+	case errors.Is(err, gocql.ErrTimeoutNoResponse):
+		return dberr.ClassTimeout
+	case errors.Is(err, gocql.ErrNoConnections), errors.Is(err, gocql.ErrConnectionClosed),
+		errors.Is(err, gocql.ErrUnavailable), errors.Is(err, gocql.ErrTooManyTimeouts):
+		return dberr.ClassUnavailable
+	case errors.As(err, &reqErr):
+		switch reqErr.Code() {
+		case gocql.ErrCodeReadTimeout, gocql.ErrCodeWriteTimeout:
+			return dberr.ClassTimeout
+		case gocql.ErrCodeUnavailable, gocql.ErrCodeOverloaded, gocql.ErrCodeBootstrapping:
+			return dberr.ClassUnavailable
+		}
+	}
+	return dberr.ClassOther
+}
+
+// Settings reports the consistency a run used, for the summary.
+func Settings(cfg config.Config) map[string]string {
+	s := map[string]string{"consistency": parseConsistency(cfg.CassandraConsistency).String()}
+	if cfg.KIndex != "" {
+		s["k_index"] = cfg.KIndex
+	}
+	if cfg.CassandraPartitionRows > 0 {
+		s["partition_rows"] = strconv.FormatInt(cfg.CassandraPartitionRows, 10)
+	}
+	return s
+}
+
 func splitHosts(s string) []string {
 	parts := strings.Split(s, ",")
 	out := make([]string, 0, len(parts))
@@ -0,0 +1,36 @@
+package cassandra
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/gocql/gocql"
+
+	"tidb-benchmarks/pkg/db/dberr"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want dberr.Class
+	}{
+		// A lightweight transaction that timed out may have applied, so
+		// it must not pass for a lost race that is safe to retry.
+		{"cas write timeout", &gocql.RequestErrWriteTimeout{WriteType: "CAS"}, dberr.ClassTimeout},
+		{"cas write unknown", &gocql.RequestErrCASWriteUnknown{}, dberr.ClassTimeout},
+		{"write timeout", &gocql.RequestErrWriteTimeout{WriteType: "SIMPLE"}, dberr.ClassTimeout},
+		{"cas exhausted", fmt.Errorf("debit: %w", errCASExhausted), dberr.ClassConflict},
+		{"not found", gocql.ErrNotFound, dberr.ClassNotFound},
+		{"no connections", gocql.ErrNoConnections, dberr.ClassUnavailable},
+		{"deadline", context.DeadlineExceeded, dberr.ClassTimeout},
+		{"other", fmt.Errorf("syntax error"), dberr.ClassOther},
+	}
+	c := &Client{}
+	for _, tt := range tests {
+		if got := c.ClassifyError(tt.err); got != tt.want {
+			t.Errorf("%s: ClassifyError(%v) = %s, want %s", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
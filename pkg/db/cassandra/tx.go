@@ -0,0 +1,118 @@
+package cassandra
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/gocql/gocql"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/db/txn"
+)
+
+// Tx is the closest Cassandra gets to a multi-statement transaction, and
+// is not one. Reads run immediately and see no uncommitted writes; writes
+// are queued into a LOGGED batch applied at Commit. The batch is atomic
+// (all writes eventually apply) but not isolated, and nothing is locked,
+// so concurrent transactions can interleave freely.
+type Tx struct {
+	c     *Client
+	cfg   config.Config
+	q     *tableQueries
+	batch *gocql.Batch
+	ts    int64
+}
+
+// Begin starts a batch. ctx bounds the statements and the Commit.
+func (c *Client) Begin(ctx context.Context, cfg config.Config) (txn.Tx, error) {
+	return &Tx{
+		c:     c,
+		cfg:   cfg,
+		q:     c.queries(cfg),
+		batch: c.session.NewBatch(gocql.LoggedBatch).WithContext(ctx),
+		ts:    time.Now().UnixMicro(),
+	}, nil
+}
+
+// nextTS gives each queued write a later timestamp than the one before,
+// so a delete and re-insert of the same row in one batch keeps the row.
+func (t *Tx) nextTS() int64 {
+	t.ts++
+	return t.ts
+}
+
+func (t *Tx) Read(ctx context.Context, id int64) ([]byte, error) {
+	return t.c.Read(ctx, t.cfg, id)
+}
+
+// RangeQuery reads the range like Scan and aggregates, orders or
+// de-duplicates client side, since CQL has no ORDER BY on c or DISTINCT
+// on non-key columns.
+func (t *Tx) RangeQuery(ctx context.Context, r txn.Range, startID int64, n int) (int, error) {
+	var (
+		sum  int64
+		cs   [][]byte
+		read int
+	)
+	err := t.c.scanRows(ctx, t.q, startID, n, func(k int64, payload []byte) {
+		read += len(payload)
+		sum += k
+		if r == txn.RangeOrder || r == txn.RangeDistinct {
+			cs = append(cs, append([]byte(nil), payload...))
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	switch r {
+	case txn.RangeSum:
+		return 0, nil
+	case txn.RangeOrder, txn.RangeDistinct:
+		sort.Slice(cs, func(i, j int) bool { return bytes.Compare(cs[i], cs[j]) < 0 })
+		if r == txn.RangeDistinct {
+			read = 0
+			for i, v := range cs {
+				if i == 0 || !bytes.Equal(v, cs[i-1]) {
+					read += len(v)
+				}
+			}
+		}
+	}
+	return read, nil
+}
+
+func (t *Tx) UpdateK(ctx context.Context, id, k int64) error {
+	t.batch.Query(t.q.txUpdateK, append([]any{t.nextTS(), k}, t.c.key(id)...)...)
+	return nil
+}
+
+func (t *Tx) UpdateC(ctx context.Context, id int64, payload []byte) error {
+	t.batch.Query(t.q.txUpdateC, append([]any{t.nextTS(), payload}, t.c.key(id)...)...)
+	return nil
+}
+
+func (t *Tx) Delete(ctx context.Context, id int64) error {
+	t.batch.Query(t.q.txDelete, append([]any{t.nextTS()}, t.c.key(id)...)...)
+	return nil
+}
+
+func (t *Tx) Insert(ctx context.Context, id, k int64, payload []byte) error {
+	t.batch.Query(t.q.txInsert, append(t.c.key(id), k, payload, t.nextTS())...)
+	return nil
+}
+
+// Commit applies the queued writes as one logged batch.
+func (t *Tx) Commit() error {
+	if t.batch.Size() == 0 {
+		return nil
+	}
+	return t.c.session.ExecuteBatch(t.batch)
+}
+
+// Rollback drops the queued writes; reads already ran.
+func (t *Tx) Rollback() error {
+	t.batch.Entries = nil
+	return nil
+}
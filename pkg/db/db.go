@@ -3,29 +3,175 @@ package db
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"tidb-benchmarks/pkg/config"
 	"tidb-benchmarks/pkg/db/cassandra"
+	"tidb-benchmarks/pkg/db/dberr"
 	"tidb-benchmarks/pkg/db/mysql"
+	"tidb-benchmarks/pkg/db/tidb"
+	"tidb-benchmarks/pkg/db/txn"
 )
 
 type Client interface {
 	Name() string
 	PrepareSchema(ctx context.Context, cfg config.Config) error
 	Truncate(ctx context.Context, cfg config.Config) error
+	// MaxID returns the highest id in the table, 0 when it is empty, so
+	// that inserts can continue past rows an earlier run inserted. A
+	// backend where inserts are upserts and cannot collide may return 0.
+	MaxID(ctx context.Context, cfg config.Config) (int64, error)
 	Insert(ctx context.Context, cfg config.Config, id int64, k int64, payload []byte) error
+	// InsertBatch inserts len(ks) rows with ids startID, startID+1, ... and
+	// k values ks, all carrying payload.
+	InsertBatch(ctx context.Context, cfg config.Config, startID int64, ks []int64, payload []byte) error
+	// DeleteRange removes the rows with ids startID..endID inclusive.
+	DeleteRange(ctx context.Context, cfg config.Config, startID, endID int64) error
 	Read(ctx context.Context, cfg config.Config, id int64) ([]byte, error)
 	Update(ctx context.Context, cfg config.Config, id int64, k int64, payload []byte) error
+	// Delete removes the row with id. Deleting a missing row is not an
+	// error.
+	Delete(ctx context.Context, cfg config.Config, id int64) error
+	// Scan reads up to limit rows starting at startID and returns the number
+	// of rows and of payload bytes read.
+	Scan(ctx context.Context, cfg config.Config, startID int64, limit int) (rows, n int, err error)
+	// ReadByK reads the rows whose k equals k through the secondary index
+	// created with --k-index and returns the number of rows and of payload
+	// bytes read.
+	ReadByK(ctx context.Context, cfg config.Config, k int64) (rows, n int, err error)
+	// Transfer moves amount from the balance of account from to that of
+	// account to, both kept in k, as atomically as the engine allows.
+	Transfer(ctx context.Context, cfg config.Config, from, to, amount int64) error
+	// Total returns the sum of k over the table and the number of rows.
+	Total(ctx context.Context, cfg config.Config) (sum int64, rows int64, err error)
+	// ReadK returns the k of id, read so that a following CompareAndSet
+	// can succeed: from the primary or leader, or at SERIAL on Cassandra.
+	ReadK(ctx context.Context, cfg config.Config, id int64) (int64, error)
+	// CompareAndSet sets k to next and the payload if k is still old. When
+	// it does not apply, cur is the k found instead.
+	CompareAndSet(ctx context.Context, cfg config.Config, id, old, next int64, payload []byte) (applied bool, cur int64, err error)
+	// Begin starts a transaction on cfg.Table; see TxSemantics for what
+	// each engine guarantees.
+	Begin(ctx context.Context, cfg config.Config) (txn.Tx, error)
+	// ClassifyError maps a driver error returned by this client to a class.
+	ClassifyError(err error) dberr.Class
 	Close() error
 }
 
 func Open(ctx context.Context, cfg config.Config) (Client, error) {
 	switch cfg.DB {
 	case config.DBMySQL:
+		if err := tidb.CheckUnset(cfg); err != nil {
+			return nil, err
+		}
 		return mysql.Open(ctx, cfg)
+	case config.DBTiDB:
+		return tidb.Open(ctx, cfg)
 	case config.DBCassandra:
+		if err := tidb.CheckUnset(cfg); err != nil {
+			return nil, err
+		}
 		return cassandra.Open(ctx, cfg)
 	default:
 		return nil, fmt.Errorf("unsupported db: %s", cfg.DB)
 	}
 }
+
+// Settings describes the backend settings that shape a run's results, such
+// as consistency or transaction mode, for recording in the summary.
+func Settings(cfg config.Config) map[string]string {
+	switch cfg.DB {
+	case config.DBMySQL:
+		return mysql.Settings(cfg)
+	case config.DBTiDB:
+		return tidb.Settings(cfg)
+	case config.DBCassandra:
+		return cassandra.Settings(cfg)
+	default:
+		return nil
+	}
+}
+
+// TxSemantics labels what a Begin/Commit pair means on cfg.DB, so that
+// transactional results are not mistaken for like-for-like.
+func TxSemantics(cfg config.Config) string {
+	switch cfg.DB {
+	case config.DBMySQL, config.DBTiDB:
+		return "transaction"
+	case config.DBCassandra:
+		return "logged-batch (not isolated)"
+	default:
+		return ""
+	}
+}
+
+// SnapshotTotals tells whether Total reads one consistent snapshot on
+// cfg.DB, making it exact while writes run.
+func SnapshotTotals(cfg config.Config) bool {
+	return cfg.DB == config.DBMySQL || cfg.DB == config.DBTiDB
+}
+
+// RetryOn returns the error classes retried with --retry-attempts: the
+// --retry-on classes, or by default those a client of cfg.DB would
+// normally retry. On MySQL/TiDB that is conflict (deadlocks, lock wait
+// timeouts, TiDB write conflicts); on Cassandra timeout and unavailable
+// (WriteTimeout, ReadTimeout, Unavailable, Overloaded).
+func RetryOn(cfg config.Config) []dberr.Class {
+	if len(cfg.RetryOn) > 0 {
+		classes := make([]dberr.Class, len(cfg.RetryOn))
+		for i, c := range cfg.RetryOn {
+			classes[i] = dberr.Class(c)
+		}
+		return classes
+	}
+	switch cfg.DB {
+	case config.DBMySQL, config.DBTiDB:
+		return []dberr.Class{dberr.ClassConflict}
+	case config.DBCassandra:
+		return []dberr.Class{dberr.ClassTimeout, dberr.ClassUnavailable}
+	default:
+		return nil
+	}
+}
+
+// RolledBackOn returns the error classes after which a failed operation
+// is known to have had no effect, so that one that is not idempotent can
+// run again. On MySQL/TiDB a conflict rolls the transaction or statement
+// back. On Cassandra no class qualifies: even a lightweight transaction
+// that timed out under contention may have applied.
+func RolledBackOn(cfg config.Config) []dberr.Class {
+	switch cfg.DB {
+	case config.DBMySQL, config.DBTiDB:
+		return []dberr.Class{dberr.ClassConflict}
+	default:
+		return nil
+	}
+}
+
+// UpsertInserts reports whether an insert on cfg.DB overwrites a row with
+// the same id, as Cassandra's do, rather than failing on the duplicate key.
+func UpsertInserts(cfg config.Config) bool {
+	return cfg.DB == config.DBCassandra
+}
+
+// ReadConsistency names the read consistency cfg selects on cfg.DB, for
+// labeling what a verification run measured.
+func ReadConsistency(cfg config.Config) string {
+	switch cfg.DB {
+	case config.DBMySQL:
+		return "primary"
+	case config.DBTiDB:
+		level := "leader"
+		if cfg.TiDBReplicaRead != "" {
+			level = strings.ToLower(cfg.TiDBReplicaRead)
+		}
+		if cfg.TiDBStaleRead > 0 {
+			level += ", stale-read " + cfg.TiDBStaleRead.String()
+		}
+		return level
+	case config.DBCassandra:
+		return cassandra.Settings(cfg)["consistency"]
+	default:
+		return ""
+	}
+}
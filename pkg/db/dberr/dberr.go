@@ -0,0 +1,40 @@
+package dberr
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+)
+
+// Class groups backend errors so runs against different engines can be
+// compared by failure kind rather than by driver-specific codes.
+type Class string
+
+const (
+	ClassTimeout     Class = "timeout"
+	ClassConflict    Class = "conflict"
+	ClassUnavailable Class = "unavailable"
+	ClassNotFound    Class = "not-found"
+	ClassOther       Class = "other"
+)
+
+// Classify maps errors that do not depend on the driver: context deadlines,
+// missing rows and network failures. ok is false when err needs a
+// driver-specific mapping.
+func Classify(err error) (c Class, ok bool) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ClassTimeout, true
+	case errors.Is(err, sql.ErrNoRows):
+		return ClassNotFound, true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ClassTimeout, true
+		}
+		return ClassUnavailable, true
+	}
+	return "", false
+}
@@ -0,0 +1,83 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+
+	"tidb-benchmarks/pkg/config"
+)
+
+// Bank modes selected by --bank-mode.
+const (
+	// BankLock reads both balances with SELECT ... FOR UPDATE, in id order
+	// so that concurrent transfers queue instead of deadlocking.
+	BankLock = "lock"
+	// BankSnapshot reads them with a plain SELECT. Under REPEATABLE READ
+	// two transfers can then read the same balance and one update is lost;
+	// TiDB's optimistic mode aborts one of them at commit instead.
+	BankSnapshot = "snapshot"
+)
+
+// Transfer moves amount from one account's balance in k to another's in
+// one transaction, writing the new balances computed from what it read.
+func (c *Client) Transfer(ctx context.Context, cfg config.Config, from, to, amount int64) (err error) {
+	if from == to {
+		return nil
+	}
+	txi, err := c.Begin(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	tx := txi.(*Tx)
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	read := stmtBankRead
+	if c.bankMode == BankLock {
+		read = stmtBankReadLock
+	}
+	rows, err := tx.query(ctx, read, c.rowID(from), c.rowID(to))
+	if err != nil {
+		return err
+	}
+	balances := make(map[int64]int64, 2)
+	for rows.Next() {
+		var id, k int64
+		if err := rows.Scan(&id, &k); err != nil {
+			rows.Close()
+			return err
+		}
+		balances[id] = k
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	fromBal, ok1 := balances[c.rowID(from)]
+	toBal, ok2 := balances[c.rowID(to)]
+	if !ok1 || !ok2 {
+		return fmt.Errorf("transfer %d -> %d: account missing", from, to)
+	}
+
+	if err := tx.UpdateK(ctx, from, fromBal-amount); err != nil {
+		return err
+	}
+	if err := tx.UpdateK(ctx, to, toBal+amount); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Total sums every balance. A single statement reads one snapshot, so the
+// sum is exact even while transfers run.
+func (c *Client) Total(ctx context.Context, cfg config.Config) (int64, int64, error) {
+	row, err := c.queryRow(ctx, cfg.Table, stmtTotal)
+	if err != nil {
+		return 0, 0, err
+	}
+	var sum, rows int64
+	err = row.Scan(&sum, &rows)
+	return sum, rows, err
+}
@@ -0,0 +1,151 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+
+	"tidb-benchmarks/pkg/config"
+)
+
+// maxPlaceholders is the MySQL protocol limit on parameters per statement.
+const maxPlaceholders = 65535
+
+// deleteStep bounds the ids one DELETE touches, keeping TiDB transactions
+// well under their size limit.
+const deleteStep = 5000
+
+// InsertBatch writes the rows with one multi-row INSERT, or with LOAD DATA
+// LOCAL INFILE from a generated stream when cfg.MySQLLoadData is set.
+func (c *Client) InsertBatch(ctx context.Context, cfg config.Config, startID int64, ks []int64, payload []byte) error {
+	if len(ks) == 0 {
+		return nil
+	}
+	if cfg.MySQLLoadData {
+		return c.loadData(ctx, cfg, startID, ks, payload)
+	}
+	if len(ks)*3 > maxPlaceholders {
+		return fmt.Errorf("batch of %d rows exceeds %d placeholders", len(ks), maxPlaceholders)
+	}
+
+	args := make([]any, 0, len(ks)*3)
+	for i, k := range ks {
+		args = append(args, c.rowID(startID+int64(i)), k, payload)
+	}
+
+	ts := c.statements(cfg.Table)
+	text := ts.batchInsertText(cfg.Table, len(ks))
+	if c.mode != PreparedServer {
+		_, err := c.db.ExecContext(ctx, text, args...)
+		return err
+	}
+	p, err := c.preparedBatchInsert(ctx, ts, text, len(ks))
+	if err != nil {
+		return err
+	}
+	_, err = p.ExecContext(ctx, args...)
+	return err
+}
+
+// batchInsertText returns the multi-row INSERT for n rows. Prepare uses one
+// batch size plus a shorter tail, so only a couple of sizes are cached.
+func (ts *tableStmts) batchInsertText(table string, n int) string {
+	if v, ok := ts.batchText.Load(n); ok {
+		return v.(string)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO %s (id, k, c) VALUES ", table)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("(?, ?, ?)")
+	}
+	v, _ := ts.batchText.LoadOrStore(n, b.String())
+	return v.(string)
+}
+
+func (c *Client) preparedBatchInsert(ctx context.Context, ts *tableStmts, text string, n int) (*sql.Stmt, error) {
+	if v, ok := ts.batchPrepared.Load(n); ok {
+		return v.(*sql.Stmt), nil
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if v, ok := ts.batchPrepared.Load(n); ok {
+		return v.(*sql.Stmt), nil
+	}
+	p, err := c.db.PrepareContext(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	ts.batchPrepared.Store(n, p)
+	return p, nil
+}
+
+var readerSeq int64
+
+// loadData streams the rows as tab-separated values with the payload
+// hex-encoded, so arbitrary bytes survive the text format.
+func (c *Client) loadData(ctx context.Context, cfg config.Config, startID int64, ks []int64, payload []byte) error {
+	name := "bench-" + strconv.FormatInt(atomic.AddInt64(&readerSeq, 1), 10)
+	pr, pw := io.Pipe()
+	mysqlDriver.RegisterReaderHandler(name, func() io.Reader { return pr })
+	defer mysqlDriver.DeregisterReaderHandler(name)
+
+	go func() {
+		hexPayload := hex.EncodeToString(payload)
+		buf := make([]byte, 0, 64+len(hexPayload))
+		for i, k := range ks {
+			buf = buf[:0]
+			buf = strconv.AppendInt(buf, c.rowID(startID+int64(i)), 10)
+			buf = append(buf, '\t')
+			buf = strconv.AppendInt(buf, k, 10)
+			buf = append(buf, '\t')
+			buf = append(buf, hexPayload...)
+			buf = append(buf, '\n')
+			if _, err := pw.Write(buf); err != nil {
+				return
+			}
+		}
+		_ = pw.Close()
+	}()
+
+	q := fmt.Sprintf("LOAD DATA LOCAL INFILE 'Reader::%s' INTO TABLE %s FIELDS TERMINATED BY '\\t' LINES TERMINATED BY '\\n' (id, k, @c) SET c = UNHEX(@c)", name, cfg.Table)
+	_, err := c.db.ExecContext(ctx, q)
+	// Unblock the writer if the server stopped reading early.
+	_ = pr.Close()
+	return err
+}
+
+// DeleteRange deletes in steps of deleteStep ids. With remapped ids the
+// range is not contiguous in the table, so each step lists its ids.
+func (c *Client) DeleteRange(ctx context.Context, cfg config.Config, startID, endID int64) error {
+	for lo := startID; lo <= endID; lo += deleteStep {
+		hi := lo + deleteStep - 1
+		if hi > endID {
+			hi = endID
+		}
+		if c.opts.RowID == nil {
+			if _, err := c.exec(ctx, cfg.Table, stmtDeleteRange, lo, hi); err != nil {
+				return err
+			}
+			continue
+		}
+		args := make([]any, 0, hi-lo+1)
+		for id := lo; id <= hi; id++ {
+			args = append(args, c.rowID(id))
+		}
+		q := fmt.Sprintf("DELETE FROM %s WHERE id IN (?%s)", cfg.Table, strings.Repeat(", ?", len(args)-1))
+		if _, err := c.db.ExecContext(ctx, q, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
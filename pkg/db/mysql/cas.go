@@ -0,0 +1,38 @@
+package mysql
+
+import (
+	"context"
+
+	"tidb-benchmarks/pkg/config"
+)
+
+// ReadK returns the k of id. It never uses --tidb-stale-read, so it sees
+// the latest committed value.
+func (c *Client) ReadK(ctx context.Context, cfg config.Config, id int64) (int64, error) {
+	row, err := c.queryRow(ctx, cfg.Table, stmtReadK, c.rowID(id))
+	if err != nil {
+		return 0, err
+	}
+	var k int64
+	err = row.Scan(&k)
+	return k, err
+}
+
+// CompareAndSet sets k to next and c to payload if k is still old, with
+// UPDATE ... WHERE id = ? AND k = ?. An update that matches no row does
+// not say what k is now, so a failed one reads it back.
+func (c *Client) CompareAndSet(ctx context.Context, cfg config.Config, id, old, next int64, payload []byte) (bool, int64, error) {
+	res, err := c.exec(ctx, cfg.Table, stmtCAS, next, payload, c.rowID(id), old)
+	if err != nil {
+		return false, 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, 0, err
+	}
+	if n == 1 {
+		return true, next, nil
+	}
+	cur, err := c.ReadK(ctx, cfg, id)
+	return false, cur, err
+}
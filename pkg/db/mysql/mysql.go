@@ -3,24 +3,83 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"sync"
 
 	mysqlDriver "github.com/go-sql-driver/mysql"
 
 	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/db/dberr"
 )
 
 type Client struct {
-	db *sql.DB
+	db       *sql.DB
+	opts     Options
+	mode     string
+	bankMode string
+
+	// tables maps a table name to its *tableStmts.
+	tables sync.Map
+}
+
+// Options lets MySQL-compatible engines reuse this client with their own
+// session settings and table layout.
+type Options struct {
+	// SessionVars are set on every new connection.
+	SessionVars map[string]string
+
+	// RowID maps a workload id to the stored primary key. Nil keeps ids as
+	// they are.
+	RowID func(id int64) int64
+	// ShardBits is the number of top bits below the sign bit that RowID
+	// fills with a shard; the workload id is in the bits below.
+	ShardBits int
+
+	// IDColumnAttrs is appended to the id column definition.
+	IDColumnAttrs string
+	// PrimaryKeyAttrs is appended to the PRIMARY KEY clause.
+	PrimaryKeyAttrs string
+	// TableOptions replaces the default ENGINE=InnoDB.
+	TableOptions string
+
+	// ReadAsOf follows the table name in point reads and scans, e.g. a
+	// TiDB AS OF TIMESTAMP clause for stale reads.
+	ReadAsOf string
 }
 
 func Open(ctx context.Context, cfg config.Config) (*Client, error) {
+	return OpenWithOptions(ctx, cfg, Options{TableOptions: "ENGINE=InnoDB"})
+}
+
+func OpenWithOptions(ctx context.Context, cfg config.Config, opts Options) (*Client, error) {
 	dsn := cfg.MySQLDSN
 	parsed, err := mysqlDriver.ParseDSN(dsn)
 	if err != nil {
 		return nil, err
 	}
 
+	bankMode := cfg.BankMode
+	switch bankMode {
+	case BankLock, BankSnapshot:
+	case "":
+		bankMode = BankLock
+	default:
+		return nil, fmt.Errorf("bank-mode must be lock or snapshot for MySQL/TiDB, got %q", cfg.BankMode)
+	}
+
+	mode := cfg.MySQLPrepared
+	switch mode {
+	case PreparedServer, PreparedPerCall:
+	case PreparedClient:
+		parsed.InterpolateParams = true
+	case "":
+		mode = PreparedServer
+	default:
+		return nil, fmt.Errorf("mysql-prepared must be server, client or per-call, got %q", cfg.MySQLPrepared)
+	}
+
 	if cfg.MySQLTLS {
 		// Enable TLS by default unless DSN already specifies tls.
 		if parsed.TLSConfig == "" {
@@ -31,6 +90,15 @@ func Open(ctx context.Context, cfg config.Config) (*Client, error) {
 		parsed.TLSConfig = "false"
 	}
 
+	if len(opts.SessionVars) > 0 {
+		if parsed.Params == nil {
+			parsed.Params = make(map[string]string, len(opts.SessionVars))
+		}
+		for k, v := range opts.SessionVars {
+			parsed.Params[k] = v
+		}
+	}
+
 	dsn = parsed.FormatDSN()
 
 	dbConn, err := sql.Open("mysql", dsn)
@@ -45,36 +113,96 @@ func Open(ctx context.Context, cfg config.Config) (*Client, error) {
 	dbConn.SetMaxOpenConns(cfg.Threads * 4)
 	dbConn.SetMaxIdleConns(cfg.Threads * 2)
 
-	return &Client{db: dbConn}, nil
+	return &Client{db: dbConn, opts: opts, mode: mode, bankMode: bankMode}, nil
 }
 
+// DB exposes the connection pool to engines built on this client.
+func (c *Client) DB() *sql.DB { return c.db }
+
 func (c *Client) Name() string { return "mysql" }
 
 func (c *Client) PrepareSchema(ctx context.Context, cfg config.Config) error {
 	ddl := fmt.Sprintf(`
 CREATE TABLE IF NOT EXISTS %s (
-	id BIGINT NOT NULL,
+	id BIGINT NOT NULL%s,
 	k BIGINT NOT NULL,
 	c BLOB NOT NULL,
-	PRIMARY KEY (id)
-) ENGINE=InnoDB;
-`, cfg.Table)
-	_, err := c.db.ExecContext(ctx, ddl)
+	PRIMARY KEY (id)%s
+) %s;
+`, cfg.Table, prefixSpace(c.opts.IDColumnAttrs), prefixSpace(c.opts.PrimaryKeyAttrs), c.opts.TableOptions)
+	if _, err := c.db.ExecContext(ctx, ddl); err != nil {
+		return err
+	}
+	return c.createKIndex(ctx, cfg)
+}
+
+// kIndexName names the secondary index on k.
+const kIndexName = "k_idx"
+
+// createKIndex adds the index on k to a table that lacks it, so --k-index
+// also applies to tables created earlier without it.
+func (c *Client) createKIndex(ctx context.Context, cfg config.Config) error {
+	switch cfg.KIndex {
+	case "":
+		return nil
+	case "on":
+	default:
+		return fmt.Errorf("k-index must be on for MySQL/TiDB, got %q", cfg.KIndex)
+	}
+	var n int
+	err := c.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?",
+		cfg.Table, kIndexName).Scan(&n)
+	if err != nil || n > 0 {
+		return err
+	}
+	_, err = c.db.ExecContext(ctx, fmt.Sprintf("CREATE INDEX %s ON %s (k)", kIndexName, cfg.Table))
 	return err
 }
 
+func prefixSpace(s string) string {
+	if s == "" {
+		return ""
+	}
+	return " " + s
+}
+
+func (c *Client) rowID(id int64) int64 {
+	if c.opts.RowID == nil {
+		return id
+	}
+	return c.opts.RowID(id)
+}
+
 func (c *Client) Truncate(ctx context.Context, cfg config.Config) error {
 	_, err := c.db.ExecContext(ctx, fmt.Sprintf("TRUNCATE TABLE %s", cfg.Table))
 	return err
 }
 
+// MaxID reads the highest id from the primary key. With shard bits the
+// shard sits in the high bits of every stored id, so it masks them off and
+// takes the maximum of what is left in one pass over the table.
+func (c *Client) MaxID(ctx context.Context, cfg config.Config) (int64, error) {
+	q := fmt.Sprintf("SELECT COALESCE(MAX(id), 0) FROM %s", cfg.Table)
+	if c.opts.ShardBits > 0 {
+		mask := int64(1)<<(63-c.opts.ShardBits) - 1
+		q = fmt.Sprintf("SELECT COALESCE(MAX(id & %d), 0) FROM %s", mask, cfg.Table)
+	}
+	var id int64
+	err := c.db.QueryRowContext(ctx, q).Scan(&id)
+	return id, err
+}
+
 func (c *Client) Insert(ctx context.Context, cfg config.Config, id int64, k int64, payload []byte) error {
-	_, err := c.db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (id, k, c) VALUES (?, ?, ?)", cfg.Table), id, k, payload)
+	_, err := c.exec(ctx, cfg.Table, stmtInsert, c.rowID(id), k, payload)
 	return err
 }
 
 func (c *Client) Read(ctx context.Context, cfg config.Config, id int64) ([]byte, error) {
-	row := c.db.QueryRowContext(ctx, fmt.Sprintf("SELECT id, k, c FROM %s WHERE id = ?", cfg.Table), id)
+	row, err := c.queryRow(ctx, cfg.Table, stmtRead, c.rowID(id))
+	if err != nil {
+		return nil, err
+	}
 	var (
 		ignoredID int64
 		ignoredK  int64
@@ -87,8 +215,120 @@ func (c *Client) Read(ctx context.Context, cfg config.Config, id int64) ([]byte,
 }
 
 func (c *Client) Update(ctx context.Context, cfg config.Config, id int64, k int64, payload []byte) error {
-	_, err := c.db.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET k = ?, c = ? WHERE id = ?", cfg.Table), k, payload, id)
+	_, err := c.exec(ctx, cfg.Table, stmtUpdate, k, payload, c.rowID(id))
+	return err
+}
+
+func (c *Client) Delete(ctx context.Context, cfg config.Config, id int64) error {
+	_, err := c.exec(ctx, cfg.Table, stmtDelete, c.rowID(id))
 	return err
 }
 
-func (c *Client) Close() error { return c.db.Close() }
+// Scan reads ids startID..startID+limit-1. When ids are remapped (see
+// Options.RowID) consecutive ids are no longer adjacent, so it reads the
+// next limit rows in primary key order instead.
+func (c *Client) Scan(ctx context.Context, cfg config.Config, startID int64, limit int) (count, n int, err error) {
+	var rows *sql.Rows
+	if c.opts.RowID != nil {
+		rows, err = c.query(ctx, cfg.Table, stmtScan, c.rowID(startID), limit)
+	} else {
+		rows, err = c.query(ctx, cfg.Table, stmtScan, startID, startID+int64(limit)-1)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+	var (
+		ignoredID int64
+		ignoredK  int64
+		payload   []byte
+	)
+	for rows.Next() {
+		if err := rows.Scan(&ignoredID, &ignoredK, &payload); err != nil {
+			return count, n, err
+		}
+		count++
+		n += len(payload)
+	}
+	return count, n, rows.Err()
+}
+
+func (c *Client) ReadByK(ctx context.Context, cfg config.Config, k int64) (count, n int, err error) {
+	rows, err := c.query(ctx, cfg.Table, stmtReadByK, k)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+	var (
+		ignoredID int64
+		ignoredK  int64
+		payload   []byte
+	)
+	for rows.Next() {
+		if err := rows.Scan(&ignoredID, &ignoredK, &payload); err != nil {
+			return count, n, err
+		}
+		count++
+		n += len(payload)
+	}
+	return count, n, rows.Err()
+}
+
+func (c *Client) Close() error {
+	c.closeStatements()
+	return c.db.Close()
+}
+
+// Settings reports the statement mode a run used, for the summary.
+func Settings(cfg config.Config) map[string]string {
+	mode := cfg.MySQLPrepared
+	if mode == "" {
+		mode = PreparedServer
+	}
+	s := map[string]string{"prepared": mode}
+	if cfg.KIndex != "" {
+		s["k_index"] = cfg.KIndex
+	}
+	return s
+}
+
+// MySQL and TiDB server error numbers mapped by ClassifyError.
+const (
+	errLockWaitTimeout   = 1205
+	errLockDeadlock      = 1213
+	errTooManyConns      = 1040
+	errServerShutdown    = 1053
+	errQueryInterrupted  = 1317
+	errMaxExecTime       = 3024
+	errTiDBPDTimeout     = 9001
+	errTiDBTiKVTimeout   = 9002
+	errTiDBServerBusy    = 9003
+	errTiDBRegionUnavail = 9005
+	errTiDBWriteConflict = 9007
+	errTiDBTxnRetryable  = 8022
+	errTiDBInfoSchema    = 8028
+)
+
+// ClassifyError maps MySQL and TiDB errors to a dberr.Class.
+func (c *Client) ClassifyError(err error) dberr.Class {
+	if class, ok := dberr.Classify(err); ok {
+		return class
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysqlDriver.ErrInvalidConn) {
+		return dberr.ClassUnavailable
+	}
+	var myErr *mysqlDriver.MySQLError
+	if !errors.As(err, &myErr) {
+		return dberr.ClassOther
+	}
+	switch myErr.Number {
+	case errLockDeadlock, errLockWaitTimeout, errTiDBWriteConflict, errTiDBTxnRetryable, errTiDBInfoSchema:
+		return dberr.ClassConflict
+	case errQueryInterrupted, errMaxExecTime, errTiDBTiKVTimeout:
+		return dberr.ClassTimeout
+	case errTooManyConns, errServerShutdown, errTiDBPDTimeout, errTiDBServerBusy, errTiDBRegionUnavail:
+		return dberr.ClassUnavailable
+	default:
+		return dberr.ClassOther
+	}
+}
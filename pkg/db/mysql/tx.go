@@ -0,0 +1,141 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/db/txn"
+)
+
+// Tx runs statements inside one *sql.Tx, so they share a connection and
+// commit or roll back together.
+type Tx struct {
+	c     *Client
+	tx    *sql.Tx
+	table string
+}
+
+// Begin starts a transaction with the session's default isolation level.
+func (c *Client) Begin(ctx context.Context, cfg config.Config) (txn.Tx, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{c: c, tx: tx, table: cfg.Table}, nil
+}
+
+// stmt returns s bound to the transaction in server mode, or nil and the
+// query text otherwise. Bound statements are closed by Commit or Rollback.
+func (t *Tx) stmt(ctx context.Context, s stmt) (*sql.Stmt, string, error) {
+	ts := t.c.statements(t.table)
+	if t.c.mode != PreparedServer {
+		return nil, ts.text[s], nil
+	}
+	p, err := t.c.prepared(ctx, ts, s)
+	if err != nil {
+		return nil, "", err
+	}
+	return t.tx.StmtContext(ctx, p), "", nil
+}
+
+func (t *Tx) exec(ctx context.Context, s stmt, args ...any) error {
+	p, text, err := t.stmt(ctx, s)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		_, err = t.tx.ExecContext(ctx, text, args...)
+		return err
+	}
+	_, err = p.ExecContext(ctx, args...)
+	return err
+}
+
+func (t *Tx) query(ctx context.Context, s stmt, args ...any) (*sql.Rows, error) {
+	p, text, err := t.stmt(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return t.tx.QueryContext(ctx, text, args...)
+	}
+	return p.QueryContext(ctx, args...)
+}
+
+func (t *Tx) Read(ctx context.Context, id int64) ([]byte, error) {
+	rows, err := t.query(ctx, stmtTxRead, t.c.rowID(id))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, sql.ErrNoRows
+	}
+	var (
+		ignoredID int64
+		ignoredK  int64
+		payload   []byte
+	)
+	if err := rows.Scan(&ignoredID, &ignoredK, &payload); err != nil {
+		return nil, err
+	}
+	return payload, rows.Close()
+}
+
+var rangeStmts = [...]stmt{
+	txn.RangeSimple:   stmtTxRangeSimple,
+	txn.RangeSum:      stmtTxRangeSum,
+	txn.RangeOrder:    stmtTxRangeOrder,
+	txn.RangeDistinct: stmtTxRangeDistinct,
+}
+
+func (t *Tx) RangeQuery(ctx context.Context, r txn.Range, startID int64, n int) (int, error) {
+	rows, err := t.query(ctx, rangeStmts[r], t.c.rangeArgs(startID, n)...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	if r == txn.RangeSum {
+		var ignoredSum sql.NullInt64
+		for rows.Next() {
+			if err := rows.Scan(&ignoredSum); err != nil {
+				return 0, err
+			}
+		}
+		return 0, rows.Err()
+	}
+	var (
+		payload []byte
+		bytes   int
+	)
+	for rows.Next() {
+		if err := rows.Scan(&payload); err != nil {
+			return bytes, err
+		}
+		bytes += len(payload)
+	}
+	return bytes, rows.Err()
+}
+
+func (t *Tx) UpdateK(ctx context.Context, id, k int64) error {
+	return t.exec(ctx, stmtUpdateK, k, t.c.rowID(id))
+}
+
+func (t *Tx) UpdateC(ctx context.Context, id int64, payload []byte) error {
+	return t.exec(ctx, stmtUpdateC, payload, t.c.rowID(id))
+}
+
+func (t *Tx) Delete(ctx context.Context, id int64) error {
+	return t.exec(ctx, stmtDelete, t.c.rowID(id))
+}
+
+func (t *Tx) Insert(ctx context.Context, id, k int64, payload []byte) error {
+	return t.exec(ctx, stmtInsert, t.c.rowID(id), k, payload)
+}
+
+func (t *Tx) Commit() error   { return t.tx.Commit() }
+func (t *Tx) Rollback() error { return t.tx.Rollback() }
@@ -0,0 +1,236 @@
+// Package retry wraps a db.Client so that operations failing with a
+// retryable error class are tried again after an exponential backoff.
+package retry
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/db"
+	"tidb-benchmarks/pkg/db/dberr"
+	"tidb-benchmarks/pkg/metrics"
+	"tidb-benchmarks/pkg/util"
+)
+
+// Policy says how often and after which errors an operation is retried.
+type Policy struct {
+	// Attempts is the number of tries per operation, at least 1.
+	Attempts int
+	// Backoff is the wait before the first retry; it doubles for every
+	// further retry up to MaxBackoff. Each wait is drawn uniformly from
+	// (0, backoff] so that workers that failed together spread out.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// On lists the error classes that are retried.
+	On []dberr.Class
+	// RolledBack lists the classes of On after which an operation that is
+	// not idempotent is retried, as it certainly had no effect.
+	RolledBack []dberr.Class
+	// UpsertInserts says inserts overwrite an existing row, so they are
+	// idempotent.
+	UpsertInserts bool
+}
+
+// NewPolicy builds the policy cfg selects, with the default retryable
+// classes of cfg.DB unless --retry-on names others.
+func NewPolicy(cfg config.Config) (Policy, error) {
+	p := Policy{
+		Attempts:      cfg.RetryAttempts,
+		Backoff:       cfg.RetryBackoff,
+		MaxBackoff:    cfg.RetryMaxBackoff,
+		On:            db.RetryOn(cfg),
+		RolledBack:    db.RolledBackOn(cfg),
+		UpsertInserts: db.UpsertInserts(cfg),
+	}
+	if p.Attempts < 1 {
+		return p, fmt.Errorf("retry-attempts must be >= 1")
+	}
+	if p.Backoff < 0 || p.MaxBackoff < p.Backoff {
+		return p, fmt.Errorf("retry-backoff must be >= 0 and <= retry-max-backoff")
+	}
+	for _, c := range p.On {
+		switch c {
+		case dberr.ClassTimeout, dberr.ClassConflict, dberr.ClassUnavailable, dberr.ClassNotFound, dberr.ClassOther:
+		default:
+			return p, fmt.Errorf("unsupported retry-on class: %s", c)
+		}
+	}
+	return p, nil
+}
+
+// Enabled reports whether the policy retries at all.
+func (p Policy) Enabled() bool { return p.Attempts > 1 }
+
+// Classes returns On as strings, for recording in the summary.
+func (p Policy) Classes() []string {
+	classes := make([]string, len(p.On))
+	for i, c := range p.On {
+		classes[i] = string(c)
+	}
+	return classes
+}
+
+// retryable reports whether an error of class c is retried. An operation
+// that is not idempotent, like a transfer or a compare-and-set, is only
+// retried after an error the engine rolled back: after a timeout it may
+// well have applied, and running it again would apply it twice.
+func (p Policy) retryable(c dberr.Class, idempotent bool) bool {
+	if !idempotent && !slices.Contains(p.RolledBack, c) {
+		return false
+	}
+	return slices.Contains(p.On, c)
+}
+
+// Client retries the single operations of the client it wraps: reads,
+// writes, scans, transfers and compare-and-set. Transactions are rerun as
+// a whole through Tx, since a conflict can surface on any statement or on
+// the commit. Schema, load and cleanup calls pass through.
+//
+// A Client is meant for one worker and is not safe for concurrent use.
+type Client struct {
+	db.Client
+	policy Policy
+	rng    *util.SplitMix64
+	rec    *metrics.RetryRecorder
+}
+
+func New(c db.Client, policy Policy, seed uint64) *Client {
+	return &Client{
+		Client: c,
+		policy: policy,
+		rng:    util.NewSplitMix64(seed),
+		rec:    metrics.NewRetryRecorder(),
+	}
+}
+
+// Recorder returns the attempts recorded since New or the last Reset.
+func (c *Client) Recorder() *metrics.RetryRecorder { return c.rec }
+
+// Reset starts a new recorder, e.g. when warmup ends.
+func (c *Client) Reset() { c.rec = metrics.NewRetryRecorder() }
+
+// Tx runs fn, which begins, runs and commits one transaction and rolls it
+// back when it fails, and reruns it from the start after a retryable
+// error.
+func (c *Client) Tx(ctx context.Context, fn func() error) error {
+	return c.do(ctx, true, fn)
+}
+
+// do runs op until it succeeds, fails with an error that is not
+// retryable, or runs out of attempts, and returns its last error.
+func (c *Client) do(ctx context.Context, idempotent bool, op func() error) error {
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := op()
+		c.rec.RecordAttempt(time.Since(start))
+		if err == nil {
+			return nil
+		}
+		class := c.Client.ClassifyError(err)
+		if !c.policy.retryable(class, idempotent) || ctx.Err() != nil {
+			return err
+		}
+		if attempt >= c.policy.Attempts {
+			c.rec.RecordGaveUp()
+			return err
+		}
+		c.rec.RecordRetry(string(class))
+		if !c.sleep(ctx, attempt) {
+			return err
+		}
+	}
+}
+
+// sleep waits out the backoff after the given attempt and reports
+// whether ctx is still live.
+func (c *Client) sleep(ctx context.Context, attempt int) bool {
+	d := c.policy.Backoff
+	for i := 1; i < attempt && d < c.policy.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, c.policy.MaxBackoff)
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	d = time.Duration(1 + c.rng.Int63n(int64(d)))
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// Insert is retried like a write that is not idempotent unless inserts are
+// upserts: an insert that timed out but applied would fail its retry on
+// the duplicate key.
+func (c *Client) Insert(ctx context.Context, cfg config.Config, id int64, k int64, payload []byte) error {
+	return c.do(ctx, c.policy.UpsertInserts, func() error { return c.Client.Insert(ctx, cfg, id, k, payload) })
+}
+
+func (c *Client) Read(ctx context.Context, cfg config.Config, id int64) ([]byte, error) {
+	var payload []byte
+	err := c.do(ctx, true, func() (err error) {
+		payload, err = c.Client.Read(ctx, cfg, id)
+		return err
+	})
+	return payload, err
+}
+
+func (c *Client) Update(ctx context.Context, cfg config.Config, id int64, k int64, payload []byte) error {
+	return c.do(ctx, true, func() error { return c.Client.Update(ctx, cfg, id, k, payload) })
+}
+
+func (c *Client) Delete(ctx context.Context, cfg config.Config, id int64) error {
+	return c.do(ctx, true, func() error { return c.Client.Delete(ctx, cfg, id) })
+}
+
+func (c *Client) Scan(ctx context.Context, cfg config.Config, startID int64, limit int) (rows, n int, err error) {
+	err = c.do(ctx, true, func() (err error) {
+		rows, n, err = c.Client.Scan(ctx, cfg, startID, limit)
+		return err
+	})
+	return rows, n, err
+}
+
+func (c *Client) ReadByK(ctx context.Context, cfg config.Config, k int64) (rows, n int, err error) {
+	err = c.do(ctx, true, func() (err error) {
+		rows, n, err = c.Client.ReadByK(ctx, cfg, k)
+		return err
+	})
+	return rows, n, err
+}
+
+func (c *Client) Transfer(ctx context.Context, cfg config.Config, from, to, amount int64) error {
+	return c.do(ctx, false, func() error { return c.Client.Transfer(ctx, cfg, from, to, amount) })
+}
+
+func (c *Client) Total(ctx context.Context, cfg config.Config) (sum int64, rows int64, err error) {
+	err = c.do(ctx, true, func() (err error) {
+		sum, rows, err = c.Client.Total(ctx, cfg)
+		return err
+	})
+	return sum, rows, err
+}
+
+func (c *Client) ReadK(ctx context.Context, cfg config.Config, id int64) (int64, error) {
+	var k int64
+	err := c.do(ctx, true, func() (err error) {
+		k, err = c.Client.ReadK(ctx, cfg, id)
+		return err
+	})
+	return k, err
+}
+
+func (c *Client) CompareAndSet(ctx context.Context, cfg config.Config, id, old, next int64, payload []byte) (applied bool, cur int64, err error) {
+	err = c.do(ctx, false, func() (err error) {
+		applied, cur, err = c.Client.CompareAndSet(ctx, cfg, id, old, next, payload)
+		return err
+	})
+	return applied, cur, err
+}
@@ -0,0 +1,129 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/db"
+	"tidb-benchmarks/pkg/db/dberr"
+)
+
+// flaky fails its calls with the errors in errs, in order, then succeeds.
+// Each error's text is its class.
+type flaky struct {
+	db.Client
+	errs  []error
+	calls int
+}
+
+func (f *flaky) next() error {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return f.errs[f.calls-1]
+	}
+	return nil
+}
+
+func (f *flaky) Read(context.Context, config.Config, int64) ([]byte, error) {
+	return nil, f.next()
+}
+
+func (f *flaky) Insert(context.Context, config.Config, int64, int64, []byte) error {
+	return f.next()
+}
+
+func (f *flaky) Transfer(context.Context, config.Config, int64, int64, int64) error {
+	return f.next()
+}
+
+func (f *flaky) ClassifyError(err error) dberr.Class { return dberr.Class(err.Error()) }
+
+func classErrors(classes ...dberr.Class) []error {
+	errs := make([]error, len(classes))
+	for i, c := range classes {
+		errs[i] = errors.New(string(c))
+	}
+	return errs
+}
+
+func TestRetries(t *testing.T) {
+	const (
+		timeout     = dberr.ClassTimeout
+		conflict    = dberr.ClassConflict
+		unavailable = dberr.ClassUnavailable
+		other       = dberr.ClassOther
+	)
+	tests := []struct {
+		name    string
+		db      config.DBKind
+		op      string
+		retryOn []string
+		errs    []dberr.Class
+		calls   int
+		wantErr bool
+	}{
+		{"read succeeds", config.DBMySQL, "read", nil, nil, 1, false},
+		{"read retried after a conflict", config.DBMySQL, "read", nil, []dberr.Class{conflict, conflict}, 3, false},
+		{"read gives up", config.DBMySQL, "read", nil, []dberr.Class{conflict, conflict, conflict}, 3, true},
+		{"read not retried after other", config.DBMySQL, "read", nil, []dberr.Class{other}, 1, true},
+		{"cassandra read retried after a timeout", config.DBCassandra, "read", nil, []dberr.Class{timeout, unavailable}, 3, false},
+		{"transfer retried after a conflict", config.DBTiDB, "transfer", nil, []dberr.Class{conflict}, 2, false},
+		{"cassandra transfer not retried after a timeout", config.DBCassandra, "transfer", nil, []dberr.Class{timeout}, 1, true},
+		{"tx rerun after a conflict", config.DBMySQL, "tx", nil, []dberr.Class{conflict}, 2, false},
+		{"cassandra tx rerun after a timeout", config.DBCassandra, "tx", nil, []dberr.Class{timeout}, 2, false},
+		{"insert retried after a conflict", config.DBMySQL, "insert", nil, []dberr.Class{conflict}, 2, false},
+		{"insert not retried after a timeout", config.DBTiDB, "insert", []string{"timeout"}, []dberr.Class{timeout}, 1, true},
+		{"cassandra insert retried after a timeout", config.DBCassandra, "insert", nil, []dberr.Class{timeout}, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.DB, cfg.RetryOn = tt.db, tt.retryOn
+			cfg.RetryAttempts, cfg.RetryBackoff, cfg.RetryMaxBackoff = 3, 0, 0
+			policy, err := NewPolicy(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := &flaky{errs: classErrors(tt.errs...)}
+			c := New(f, policy, 1)
+			ctx := context.Background()
+			switch tt.op {
+			case "read":
+				_, err = c.Read(ctx, cfg, 1)
+			case "insert":
+				err = c.Insert(ctx, cfg, 1, 0, nil)
+			case "transfer":
+				err = c.Transfer(ctx, cfg, 1, 2, 10)
+			case "tx":
+				err = c.Tx(ctx, func() error { return f.next() })
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if f.calls != tt.calls {
+				t.Errorf("%d calls, want %d", f.calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestNewPolicyRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *config.Config)
+	}{
+		{"no attempts", func(cfg *config.Config) { cfg.RetryAttempts = 0 }},
+		{"negative backoff", func(cfg *config.Config) { cfg.RetryBackoff = -1 }},
+		{"backoff above max", func(cfg *config.Config) { cfg.RetryBackoff, cfg.RetryMaxBackoff = 2, 1 }},
+		{"unknown class", func(cfg *config.Config) { cfg.RetryOn = []string{"sometimes"} }},
+	}
+	for _, tt := range tests {
+		cfg := config.Default()
+		tt.modify(&cfg)
+		if _, err := NewPolicy(cfg); err == nil {
+			t.Errorf("%s: NewPolicy succeeded, want an error", tt.name)
+		}
+	}
+}
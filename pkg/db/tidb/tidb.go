@@ -0,0 +1,234 @@
+package tidb
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/db/mysql"
+)
+
+// Client talks to TiDB over the MySQL protocol and adds TiDB-specific
+// table layout options.
+type Client struct {
+	*mysql.Client
+}
+
+func Open(ctx context.Context, cfg config.Config) (*Client, error) {
+	opts, err := options(cfg)
+	if err != nil {
+		return nil, err
+	}
+	mc, err := mysql.OpenWithOptions(ctx, cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Client: mc}, nil
+}
+
+func options(cfg config.Config) (mysql.Options, error) {
+	var (
+		opts      mysql.Options
+		tableOpts []string
+		err       error
+	)
+
+	opts.SessionVars, err = sessionVars(cfg)
+	if err != nil {
+		return opts, err
+	}
+	if cfg.TiDBStaleRead < 0 {
+		return opts, fmt.Errorf("tidb-stale-read must be >= 0")
+	}
+	if cfg.TiDBStaleRead > 0 {
+		opts.ReadAsOf = fmt.Sprintf("AS OF TIMESTAMP NOW(6) - INTERVAL %d MICROSECOND", cfg.TiDBStaleRead.Microseconds())
+	}
+
+	switch strings.ToLower(cfg.TiDBClusteredIndex) {
+	case "":
+	case "clustered":
+		opts.PrimaryKeyAttrs = "CLUSTERED"
+	case "nonclustered":
+		opts.PrimaryKeyAttrs = "NONCLUSTERED"
+	default:
+		return opts, fmt.Errorf("tidb-clustered-index must be clustered or nonclustered, got %q", cfg.TiDBClusteredIndex)
+	}
+
+	if bits := cfg.TiDBAutoRandomBits; bits != 0 {
+		if bits < 1 || bits > 15 {
+			return opts, fmt.Errorf("tidb-auto-random must be in [1, 15]")
+		}
+		if opts.PrimaryKeyAttrs == "NONCLUSTERED" {
+			return opts, fmt.Errorf("tidb-auto-random requires a clustered primary key")
+		}
+		opts.PrimaryKeyAttrs = "CLUSTERED"
+		opts.IDColumnAttrs = fmt.Sprintf("AUTO_RANDOM(%d)", bits)
+		// The benchmark addresses rows by id, so ids are written explicitly
+		// with the shard bits AUTO_RANDOM would have chosen.
+		opts.SessionVars["allow_auto_random_explicit_insert"] = "1"
+		opts.RowID = func(id int64) int64 { return shardedID(id, bits) }
+		opts.ShardBits = bits
+	}
+
+	if bits := cfg.TiDBShardRowIDBits; bits != 0 {
+		if opts.PrimaryKeyAttrs != "NONCLUSTERED" {
+			return opts, fmt.Errorf("tidb-shard-row-id-bits requires --tidb-clustered-index=nonclustered")
+		}
+		tableOpts = append(tableOpts, fmt.Sprintf("SHARD_ROW_ID_BITS=%d", bits))
+	}
+
+	if n := cfg.TiDBPreSplitRegions; n != 0 {
+		if cfg.TiDBShardRowIDBits == 0 && cfg.TiDBAutoRandomBits == 0 {
+			return opts, fmt.Errorf("tidb-pre-split-regions requires tidb-shard-row-id-bits or tidb-auto-random")
+		}
+		tableOpts = append(tableOpts, fmt.Sprintf("PRE_SPLIT_REGIONS=%d", n))
+	}
+
+	opts.TableOptions = strings.Join(tableOpts, " ")
+	return opts, nil
+}
+
+// CheckUnset returns an error naming a TiDB flag that is set in cfg, for
+// the other backends, which would silently ignore it.
+func CheckUnset(cfg config.Config) error {
+	for _, f := range []struct {
+		flag string
+		set  bool
+	}{
+		{"tidb-clustered-index", cfg.TiDBClusteredIndex != ""},
+		{"tidb-auto-random", cfg.TiDBAutoRandomBits != 0},
+		{"tidb-shard-row-id-bits", cfg.TiDBShardRowIDBits != 0},
+		{"tidb-pre-split-regions", cfg.TiDBPreSplitRegions != 0},
+		{"tidb-split-regions", cfg.TiDBSplitRegions != 0},
+		{"tidb-txn-mode", cfg.TiDBTxnMode != ""},
+		{"tidb-async-commit", cfg.TiDBAsyncCommit != ""},
+		{"tidb-1pc", cfg.TiDB1PC != ""},
+		{"tidb-replica-read", cfg.TiDBReplicaRead != ""},
+		{"tidb-stale-read", cfg.TiDBStaleRead != 0},
+	} {
+		if f.set {
+			return fmt.Errorf("%s needs --db tidb", f.flag)
+		}
+	}
+	return nil
+}
+
+// sessionVars translates the TiDB session flags into variables set on every
+// connection. Unset flags keep the server defaults.
+func sessionVars(cfg config.Config) (map[string]string, error) {
+	vars := make(map[string]string)
+
+	switch mode := strings.ToLower(cfg.TiDBTxnMode); mode {
+	case "":
+	case "optimistic", "pessimistic":
+		vars["tidb_txn_mode"] = "'" + mode + "'"
+	default:
+		return nil, fmt.Errorf("tidb-txn-mode must be optimistic or pessimistic, got %q", cfg.TiDBTxnMode)
+	}
+
+	for _, sw := range []struct {
+		flag, value, variable string
+	}{
+		{"tidb-async-commit", cfg.TiDBAsyncCommit, "tidb_enable_async_commit"},
+		{"tidb-1pc", cfg.TiDB1PC, "tidb_enable_1pc"},
+	} {
+		switch v := strings.ToUpper(sw.value); v {
+		case "":
+		case "ON", "OFF":
+			vars[sw.variable] = v
+		default:
+			return nil, fmt.Errorf("%s must be on or off, got %q", sw.flag, sw.value)
+		}
+	}
+
+	switch mode := strings.ToLower(cfg.TiDBReplicaRead); mode {
+	case "":
+	case "leader", "follower", "leader-and-follower":
+		vars["tidb_replica_read"] = "'" + mode + "'"
+	default:
+		return nil, fmt.Errorf("tidb-replica-read must be leader, follower or leader-and-follower, got %q", cfg.TiDBReplicaRead)
+	}
+
+	return vars, nil
+}
+
+// Settings reports the session settings a run used, for the summary.
+func Settings(cfg config.Config) map[string]string {
+	s := mysql.Settings(cfg)
+	s["txn_mode"] = orDefault(cfg.TiDBTxnMode)
+	s["async_commit"] = orDefault(cfg.TiDBAsyncCommit)
+	s["1pc"] = orDefault(cfg.TiDB1PC)
+	s["replica_read"] = orDefault(cfg.TiDBReplicaRead)
+	if cfg.TiDBStaleRead > 0 {
+		s["stale_read"] = cfg.TiDBStaleRead.String()
+	}
+	return s
+}
+
+func orDefault(s string) string {
+	if s == "" {
+		return "default"
+	}
+	return strings.ToLower(s)
+}
+
+// shardedID places a hash of id in the top bits below the sign bit, the
+// same layout AUTO_RANDOM uses, so consecutive ids land in different
+// regions.
+func shardedID(id int64, bits int) int64 {
+	shard := (uint64(id) * 0x9e3779b97f4a7c15) >> (64 - bits)
+	return int64(shard<<(63-bits)) | id
+}
+
+func (c *Client) Name() string { return "tidb" }
+
+// PrepareSchema creates the table and, when requested, splits it into
+// regions, so that resumed loads, --id-range loaders and runs against a
+// fresh table start split too. Splitting at boundaries that already exist
+// changes nothing.
+func (c *Client) PrepareSchema(ctx context.Context, cfg config.Config) error {
+	if err := c.Client.PrepareSchema(ctx, cfg); err != nil {
+		return err
+	}
+	return c.split(ctx, cfg)
+}
+
+// Truncate empties the table and, when requested, splits it into regions
+// again; TRUNCATE gives the table a new id, which drops earlier splits.
+func (c *Client) Truncate(ctx context.Context, cfg config.Config) error {
+	if err := c.Client.Truncate(ctx, cfg); err != nil {
+		return err
+	}
+	return c.split(ctx, cfg)
+}
+
+// split pre-splits the row data of the table and, for a non-clustered
+// table, its primary key index. Rows are keyed by their handle: the id of a
+// clustered table, else the hidden _tidb_rowid. Handles with shard bits, from
+// AUTO_RANDOM or SHARD_ROW_ID_BITS, spread over all non-negative values
+// rather than [1, table-size], so that range is split instead.
+func (c *Client) split(ctx context.Context, cfg config.Config) error {
+	if cfg.TiDBSplitRegions <= 0 {
+		return nil
+	}
+	lower, upper := int64(1), cfg.TableSize+1
+	if cfg.TiDBAutoRandomBits > 0 || cfg.TiDBShardRowIDBits > 0 {
+		lower, upper = 0, math.MaxInt64
+	}
+	qs := []string{fmt.Sprintf("SPLIT TABLE %s BETWEEN (%d) AND (%d) REGIONS %d", cfg.Table, lower, upper, cfg.TiDBSplitRegions)}
+	if strings.EqualFold(cfg.TiDBClusteredIndex, "nonclustered") {
+		qs = append(qs, fmt.Sprintf("SPLIT TABLE %s INDEX `PRIMARY` BETWEEN (1) AND (%d) REGIONS %d", cfg.Table, cfg.TableSize+1, cfg.TiDBSplitRegions))
+	}
+	for _, q := range qs {
+		rows, err := c.DB().QueryContext(ctx, q)
+		if err != nil {
+			return err
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package tidb
+
+import (
+	"testing"
+	"time"
+
+	"tidb-benchmarks/pkg/config"
+)
+
+func TestShardedID(t *testing.T) {
+	tests := []struct {
+		bits int
+		ids  []int64
+	}{
+		{1, []int64{1, 2, 3, 1000}},
+		{5, []int64{1, 2, 3, 100000}},
+		{15, []int64{1, 42, 1 << 40}},
+	}
+	for _, tt := range tests {
+		shards := make(map[int64]bool)
+		low := int64(1)<<(63-tt.bits) - 1
+		for _, id := range tt.ids {
+			got := shardedID(id, tt.bits)
+			if got < 0 {
+				t.Errorf("shardedID(%d, %d) = %d, want >= 0", id, tt.bits, got)
+			}
+			if got&low != id {
+				t.Errorf("shardedID(%d, %d) = %#x, low bits %d, want the id", id, tt.bits, got, got&low)
+			}
+			if again := shardedID(id, tt.bits); again != got {
+				t.Errorf("shardedID(%d, %d) = %d, then %d", id, tt.bits, got, again)
+			}
+			shards[got>>(63-tt.bits)] = true
+		}
+		if len(shards) < 2 {
+			t.Errorf("bits %d: ids %v all land in one shard", tt.bits, tt.ids)
+		}
+	}
+}
+
+func TestShardedIDSpreads(t *testing.T) {
+	for _, bits := range []int{2, 5, 8} {
+		shards := 1 << bits
+		counts := make([]int, shards)
+		const n = 10000
+		for id := int64(1); id <= n; id++ {
+			counts[shardedID(id, bits)>>(63-bits)]++
+		}
+		for s, c := range counts {
+			if want := n / shards; c < want/2 || c > want*2 {
+				t.Errorf("bits %d: shard %d holds %d of %d consecutive ids, want about %d", bits, s, c, n, want)
+			}
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(cfg *config.Config)
+		pk      string
+		table   string
+		wantErr bool
+	}{
+		{"defaults", func(cfg *config.Config) {}, "", "", false},
+		{"clustered", func(cfg *config.Config) { cfg.TiDBClusteredIndex = "CLUSTERED" }, "CLUSTERED", "", false},
+		{"bad layout", func(cfg *config.Config) { cfg.TiDBClusteredIndex = "heap" }, "", "", true},
+		{"auto random", func(cfg *config.Config) { cfg.TiDBAutoRandomBits = 5 }, "CLUSTERED", "", false},
+		{"auto random too wide", func(cfg *config.Config) { cfg.TiDBAutoRandomBits = 16 }, "", "", true},
+		{"auto random nonclustered", func(cfg *config.Config) {
+			cfg.TiDBAutoRandomBits, cfg.TiDBClusteredIndex = 5, "nonclustered"
+		}, "", "", true},
+		{"shard row id", func(cfg *config.Config) {
+			cfg.TiDBShardRowIDBits, cfg.TiDBClusteredIndex = 4, "nonclustered"
+		}, "NONCLUSTERED", "SHARD_ROW_ID_BITS=4", false},
+		{"shard row id clustered", func(cfg *config.Config) { cfg.TiDBShardRowIDBits = 4 }, "", "", true},
+		{"pre-split", func(cfg *config.Config) {
+			cfg.TiDBShardRowIDBits, cfg.TiDBClusteredIndex, cfg.TiDBPreSplitRegions = 4, "nonclustered", 3
+		}, "NONCLUSTERED", "SHARD_ROW_ID_BITS=4 PRE_SPLIT_REGIONS=3", false},
+		{"pre-split unsharded", func(cfg *config.Config) { cfg.TiDBPreSplitRegions = 3 }, "", "", true},
+		{"bad txn mode", func(cfg *config.Config) { cfg.TiDBTxnMode = "eventual" }, "", "", true},
+		{"bad 1pc", func(cfg *config.Config) { cfg.TiDB1PC = "maybe" }, "", "", true},
+		{"negative stale read", func(cfg *config.Config) { cfg.TiDBStaleRead = -time.Second }, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			tt.modify(&cfg)
+			opts, err := options(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("options() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if opts.PrimaryKeyAttrs != tt.pk || opts.TableOptions != tt.table {
+				t.Errorf("options() = %q, %q, want %q, %q", opts.PrimaryKeyAttrs, opts.TableOptions, tt.pk, tt.table)
+			}
+		})
+	}
+}
+
+func TestCheckUnset(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(cfg *config.Config)
+		wantErr bool
+	}{
+		{"none", func(cfg *config.Config) {}, false},
+		{"layout", func(cfg *config.Config) { cfg.TiDBClusteredIndex = "clustered" }, true},
+		{"split", func(cfg *config.Config) { cfg.TiDBSplitRegions = 8 }, true},
+		{"session", func(cfg *config.Config) { cfg.TiDBReplicaRead = "follower" }, true},
+		{"stale read", func(cfg *config.Config) { cfg.TiDBStaleRead = time.Second }, true},
+	}
+	for _, tt := range tests {
+		cfg := config.Default()
+		tt.modify(&cfg)
+		if err := CheckUnset(cfg); (err != nil) != tt.wantErr {
+			t.Errorf("%s: CheckUnset() error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
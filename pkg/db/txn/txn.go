@@ -0,0 +1,35 @@
+package txn
+
+import "context"
+
+// Range selects one of the sysbench range queries.
+type Range int
+
+const (
+	// RangeSimple reads the rows of the range.
+	RangeSimple Range = iota
+	// RangeSum sums k over the range.
+	RangeSum
+	// RangeOrder reads the rows of the range ordered by c.
+	RangeOrder
+	// RangeDistinct reads the distinct c values of the range, ordered.
+	RangeDistinct
+)
+
+// Tx groups the statements of one unit of work. Engines without
+// multi-statement transactions provide the closest equivalent they have
+// and say so in Semantics.
+type Tx interface {
+	Read(ctx context.Context, id int64) ([]byte, error)
+	// RangeQuery runs r over n ids from startID and returns the payload
+	// bytes read.
+	RangeQuery(ctx context.Context, r Range, startID int64, n int) (int, error)
+	// UpdateK sets k, the column --k-index indexes.
+	UpdateK(ctx context.Context, id, k int64) error
+	// UpdateC sets the non-indexed column c.
+	UpdateC(ctx context.Context, id int64, payload []byte) error
+	Delete(ctx context.Context, id int64) error
+	Insert(ctx context.Context, id, k int64, payload []byte) error
+	Commit() error
+	Rollback() error
+}
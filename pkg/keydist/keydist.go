@@ -0,0 +1,303 @@
+package keydist
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/util"
+)
+
+// Chooser picks the next key id for a workload operation.
+// Implementations are safe for concurrent use; per-worker randomness comes
+// from the rng passed to Next.
+type Chooser interface {
+	Next(rng *util.SplitMix64) int64
+}
+
+// New builds the chooser selected by cfg.KeyDist over ids [1, n], where n
+// is what items reports at the time of each pick: the highest id inserted
+// so far, so that ids inserted during a run are chosen as well. A nil
+// items fixes n at cfg.TableSize.
+func New(cfg config.Config, items func() int64) (Chooser, error) {
+	if cfg.TableSize <= 0 {
+		return nil, fmt.Errorf("table-size must be > 0")
+	}
+	if items == nil {
+		n := cfg.TableSize
+		items = func() int64 { return n }
+	}
+
+	switch cfg.KeyDist {
+	case config.KeyDistUniform, "":
+		return uniform{items: items}, nil
+	case config.KeyDistZipfian:
+		return newZipfian(items, cfg.ZipfianTheta)
+	case config.KeyDistScrambled:
+		z, err := newZipfian(items, cfg.ZipfianTheta)
+		if err != nil {
+			return nil, err
+		}
+		return scrambled{z: z}, nil
+	case config.KeyDistHotspot:
+		if cfg.HotspotFraction <= 0 || cfg.HotspotFraction >= 1 {
+			return nil, fmt.Errorf("hotspot-fraction must be in (0, 1)")
+		}
+		if cfg.HotspotProbability < 0 || cfg.HotspotProbability > 1 {
+			return nil, fmt.Errorf("hotspot-probability must be in [0, 1]")
+		}
+		return hotspot{items: items, fraction: cfg.HotspotFraction, prob: cfg.HotspotProbability}, nil
+	case config.KeyDistLatest:
+		z, err := newZipfian(items, cfg.ZipfianTheta)
+		if err != nil {
+			return nil, err
+		}
+		return latestChooser{z: z}, nil
+	case config.KeyDistSequential:
+		return &sequential{items: items}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key distribution: %s", cfg.KeyDist)
+	}
+}
+
+// Describe returns the distribution name and the parameters that shape it,
+// for recording alongside results.
+func Describe(cfg config.Config) (string, map[string]float64) {
+	switch cfg.KeyDist {
+	case config.KeyDistZipfian, config.KeyDistScrambled, config.KeyDistLatest:
+		return string(cfg.KeyDist), map[string]float64{"theta": cfg.ZipfianTheta}
+	case config.KeyDistHotspot:
+		return string(cfg.KeyDist), map[string]float64{
+			"hot_fraction":    cfg.HotspotFraction,
+			"hot_probability": cfg.HotspotProbability,
+		}
+	case "":
+		return string(config.KeyDistUniform), nil
+	default:
+		return string(cfg.KeyDist), nil
+	}
+}
+
+// count returns what items reports, at least 1.
+func count(items func() int64) int64 {
+	return max(items(), 1)
+}
+
+type uniform struct {
+	items func() int64
+}
+
+func (u uniform) Next(rng *util.SplitMix64) int64 {
+	return 1 + rng.Int63n(count(u.items))
+}
+
+// zipfian implements the Gray et al. generator used by YCSB. Rank 0 is the
+// most popular item and maps to id 1. As in YCSB, zeta(n) is extended
+// incrementally when the item count grows rather than summed again.
+type zipfian struct {
+	items func() int64
+	theta float64
+	alpha float64
+	zeta2 float64
+	half  float64
+
+	mu    sync.Mutex
+	state atomic.Pointer[zipfState]
+}
+
+// zipfState holds what depends on the item count n.
+type zipfState struct {
+	n     int64
+	zetan float64
+	eta   float64
+}
+
+func newZipfian(items func() int64, theta float64) (*zipfian, error) {
+	if theta <= 0 || theta >= 1 {
+		return nil, fmt.Errorf("zipfian-theta must be in (0, 1)")
+	}
+	z := &zipfian{
+		items: items,
+		theta: theta,
+		alpha: 1 / (1 - theta),
+		zeta2: zeta(2, theta),
+		half:  1 + math.Pow(0.5, theta),
+	}
+	z.state.Store(&zipfState{})
+	return z, nil
+}
+
+// zeta returns the sum of 1/i^theta for i in [1, n].
+func zeta(n int64, theta float64) float64 {
+	return zetaFrom(0, 0, n, theta)
+}
+
+// zetaCache keeps every zeta(n) computed, per theta and n. Summing n terms
+// takes a while for large tables, and every run, repeat and sweep step
+// starts a new chooser over the same or a slightly larger table.
+var zetaCache struct {
+	mu sync.Mutex
+	z  map[zetaKey]float64
+}
+
+type zetaKey struct {
+	theta float64
+	n     int64
+}
+
+// cachedZeta returns zeta(n), starting from the cached n closest to it.
+func cachedZeta(n int64, theta float64) float64 {
+	zetaCache.mu.Lock()
+	defer zetaCache.mu.Unlock()
+	if zetaCache.z == nil {
+		zetaCache.z = make(map[zetaKey]float64)
+	}
+	if z, ok := zetaCache.z[zetaKey{theta, n}]; ok {
+		return z
+	}
+	var m int64
+	var zetaM float64
+	for key, z := range zetaCache.z {
+		if key.theta == theta && absDiff(key.n, n) < absDiff(m, n) {
+			m, zetaM = key.n, z
+		}
+	}
+	z := zetaFrom(m, zetaM, n, theta)
+	zetaCache.z[zetaKey{theta, n}] = z
+	return z
+}
+
+func absDiff(a, b int64) int64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// zetaFrom returns zeta(n) given zetaM = zeta(m), adding or removing the
+// terms between m and n. Removing more terms than n sums from scratch
+// instead, which is faster and does not lose precision to the subtraction.
+func zetaFrom(m int64, zetaM float64, n int64, theta float64) float64 {
+	if n < m && n < m-n {
+		m, zetaM = 0, 0
+	}
+	sum := zetaM
+	for i := m + 1; i <= n; i++ {
+		sum += 1 / math.Pow(float64(i), theta)
+	}
+	for i := n + 1; i <= m; i++ {
+		sum -= 1 / math.Pow(float64(i), theta)
+	}
+	return sum
+}
+
+// stateFor returns the state for n items, computing it on first use.
+func (z *zipfian) stateFor(n int64) *zipfState {
+	if s := z.state.Load(); s.n == n {
+		return s
+	}
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	s := z.state.Load()
+	if s.n == n {
+		return s
+	}
+	next := &zipfState{n: n}
+	if s.n == 0 {
+		next.zetan = cachedZeta(n, z.theta)
+	} else {
+		next.zetan = zetaFrom(s.n, s.zetan, n, z.theta)
+	}
+	next.eta = (1 - math.Pow(2/float64(n), 1-z.theta)) / (1 - z.zeta2/next.zetan)
+	z.state.Store(next)
+	return next
+}
+
+// rank returns a zipfian-distributed rank in [0, n) for n items.
+func (z *zipfian) rank(rng *util.SplitMix64, n int64) int64 {
+	s := z.stateFor(n)
+	u := rng.Float64()
+	uz := u * s.zetan
+	if uz < 1 {
+		return 0
+	}
+	if uz < z.half {
+		return 1
+	}
+	r := int64(float64(n) * math.Pow(s.eta*u-s.eta+1, z.alpha))
+	if r >= n {
+		r = n - 1
+	}
+	return r
+}
+
+func (z *zipfian) Next(rng *util.SplitMix64) int64 {
+	return 1 + z.rank(rng, count(z.items))
+}
+
+// scrambled spreads the popular zipfian ranks over the whole id range by
+// hashing them, like YCSB's ScrambledZipfianGenerator, so the hottest ids
+// are not the first few adjacent ones, which one TiDB region would hold.
+type scrambled struct {
+	z *zipfian
+}
+
+func (s scrambled) Next(rng *util.SplitMix64) int64 {
+	n := count(s.z.items)
+	return 1 + fnvHash64(s.z.rank(rng, n))%n
+}
+
+// fnvHash64 is the FNV-1a hash of the 8 bytes of v, as YCSB computes it,
+// with the sign bit cleared.
+func fnvHash64(v int64) int64 {
+	h := uint64(0xcbf29ce484222325)
+	u := uint64(v)
+	for i := 0; i < 8; i++ {
+		h ^= u & 0xff
+		h *= 0x100000001b3
+		u >>= 8
+	}
+	return int64(h & math.MaxInt64)
+}
+
+// hotspot sends prob of the operations to the first fraction of the ids
+// and the rest uniformly to the remaining ids.
+type hotspot struct {
+	items    func() int64
+	fraction float64
+	prob     float64
+}
+
+func (h hotspot) Next(rng *util.SplitMix64) int64 {
+	n := count(h.items)
+	hot := max(int64(float64(n)*h.fraction), 1)
+	if hot >= n || rng.Float64() < h.prob {
+		return 1 + rng.Int63n(hot)
+	}
+	return 1 + hot + rng.Int63n(n-hot)
+}
+
+// latestChooser favours the most recently inserted ids, with popularity
+// falling off zipfian-style with distance from the newest id.
+type latestChooser struct {
+	z *zipfian
+}
+
+func (l latestChooser) Next(rng *util.SplitMix64) int64 {
+	n := count(l.z.items)
+	return n - l.z.rank(rng, n)
+}
+
+// sequential walks ids in order across all workers, wrapping at the item
+// count.
+type sequential struct {
+	items func() int64
+	next  int64
+}
+
+func (s *sequential) Next(_ *util.SplitMix64) int64 {
+	c := atomic.AddInt64(&s.next, 1)
+	return 1 + (c-1)%count(s.items)
+}
@@ -0,0 +1,301 @@
+package keydist
+
+import (
+	"math"
+	"testing"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/util"
+)
+
+// draws is the sample size of the distribution tests. With a fixed seed
+// the results are deterministic; the tolerances leave room for changing it.
+const draws = 200000
+
+func testConfig(dist config.KeyDist, tableSize int64) config.Config {
+	cfg := config.Default()
+	cfg.KeyDist = dist
+	cfg.TableSize = tableSize
+	return cfg
+}
+
+// histogram draws from c and counts every id, failing on ids outside
+// [1, n].
+func histogram(t *testing.T, c Chooser, n int64) map[int64]int {
+	t.Helper()
+	rng := util.NewSplitMix64(1)
+	counts := make(map[int64]int)
+	for i := 0; i < draws; i++ {
+		id := c.Next(rng)
+		if id < 1 || id > n {
+			t.Fatalf("id %d outside [1, %d]", id, n)
+		}
+		counts[id]++
+	}
+	return counts
+}
+
+func TestNewRejectsBadConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *config.Config)
+	}{
+		{"table size", func(cfg *config.Config) { cfg.TableSize = 0 }},
+		{"unknown", func(cfg *config.Config) { cfg.KeyDist = "gaussian" }},
+		{"zipfian theta 0", func(cfg *config.Config) { cfg.KeyDist, cfg.ZipfianTheta = config.KeyDistZipfian, 0 }},
+		{"zipfian theta 1", func(cfg *config.Config) { cfg.KeyDist, cfg.ZipfianTheta = config.KeyDistZipfian, 1 }},
+		{"hotspot fraction 0", func(cfg *config.Config) { cfg.KeyDist, cfg.HotspotFraction = config.KeyDistHotspot, 0 }},
+		{"hotspot fraction 1", func(cfg *config.Config) { cfg.KeyDist, cfg.HotspotFraction = config.KeyDistHotspot, 1 }},
+		{"hotspot probability", func(cfg *config.Config) { cfg.KeyDist, cfg.HotspotProbability = config.KeyDistHotspot, 1.5 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(config.KeyDistUniform, 100)
+			tt.modify(&cfg)
+			if _, err := New(cfg, nil); err == nil {
+				t.Fatal("New succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestNextStaysInRange(t *testing.T) {
+	for _, dist := range []config.KeyDist{
+		config.KeyDistUniform,
+		config.KeyDistZipfian,
+		config.KeyDistHotspot,
+		config.KeyDistSequential,
+	} {
+		for _, n := range []int64{1, 2, 10, 1000} {
+			c, err := New(testConfig(dist, n), nil)
+			if err != nil {
+				t.Fatalf("%s over %d: %v", dist, n, err)
+			}
+			histogram(t, c, n)
+		}
+	}
+}
+
+func TestZipfianSkew(t *testing.T) {
+	tests := []struct {
+		n     int64
+		theta float64
+	}{
+		{100, 0.99},
+		{1000, 0.99},
+		{1000, 0.5},
+		{100000, 0.99},
+	}
+	for _, tt := range tests {
+		cfg := testConfig(config.KeyDistZipfian, tt.n)
+		cfg.ZipfianTheta = tt.theta
+		c, err := New(cfg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := histogram(t, c, tt.n)
+
+		// The first two ranks are drawn exactly, with probabilities
+		// 1/zeta(n) and 2^-theta/zeta(n).
+		zetan := zeta(tt.n, tt.theta)
+		for _, id := range []int64{1, 2} {
+			want := math.Pow(float64(id), -tt.theta) / zetan
+			got := float64(counts[id]) / draws
+			if math.Abs(got-want) > 0.1*want {
+				t.Errorf("n=%d theta=%g: id %d drawn %.4f of the time, want %.4f", tt.n, tt.theta, id, got, want)
+			}
+		}
+		if counts[1] <= counts[10] || counts[10] <= counts[tt.n] {
+			t.Errorf("n=%d theta=%g: counts of ids 1, 10, n are %d, %d, %d, want decreasing",
+				tt.n, tt.theta, counts[1], counts[10], counts[tt.n])
+		}
+	}
+}
+
+func TestZetaFrom(t *testing.T) {
+	tests := []struct {
+		m, n int64
+	}{
+		{0, 0},
+		{0, 10},
+		{10, 10},
+		{10, 1000},
+		{1000, 10},
+		{1000, 0},
+		{1000, 600},
+		{1000000, 10},
+	}
+	for _, tt := range tests {
+		got := zetaFrom(tt.m, zeta(tt.m, 0.99), tt.n, 0.99)
+		if want := zeta(tt.n, 0.99); math.Abs(got-want) > 1e-9 {
+			t.Errorf("zetaFrom(%d, zeta(%d), %d) = %g, want %g", tt.m, tt.m, tt.n, got, want)
+		}
+	}
+}
+
+func TestCachedZeta(t *testing.T) {
+	// Grow, shrink a little and a lot, and switch theta in between, as
+	// runs, repeats and sweep steps over different tables do.
+	tests := []struct {
+		n     int64
+		theta float64
+	}{
+		{1000, 0.99},
+		{100000, 0.99},
+		{100000, 0.5},
+		{99000, 0.99},
+		{10, 0.99},
+		{1000, 0.5},
+		{100000, 0.99},
+	}
+	for _, tt := range tests {
+		got := cachedZeta(tt.n, tt.theta)
+		if want := zeta(tt.n, tt.theta); math.Abs(got-want) > 1e-9 {
+			t.Errorf("cachedZeta(%d, %g) = %g, want %g", tt.n, tt.theta, got, want)
+		}
+	}
+}
+
+func TestHotspot(t *testing.T) {
+	tests := []struct {
+		n              int64
+		fraction, prob float64
+	}{
+		{1000, 0.2, 0.8},
+		{1000, 0.1, 0.5},
+		{1000, 0.5, 1},
+		{1000, 0.2, 0},
+	}
+	for _, tt := range tests {
+		cfg := testConfig(config.KeyDistHotspot, tt.n)
+		cfg.HotspotFraction, cfg.HotspotProbability = tt.fraction, tt.prob
+		c, err := New(cfg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := histogram(t, c, tt.n)
+		hot := int64(float64(tt.n) * tt.fraction)
+		var inHot int
+		for id := int64(1); id <= hot; id++ {
+			inHot += counts[id]
+		}
+		if got := float64(inHot) / draws; math.Abs(got-tt.prob) > 0.01 {
+			t.Errorf("fraction=%g prob=%g: %.3f of the draws hit the hot ids", tt.fraction, tt.prob, got)
+		}
+	}
+}
+
+func TestItemsFollowGrowth(t *testing.T) {
+	for _, dist := range []config.KeyDist{
+		config.KeyDistUniform,
+		config.KeyDistZipfian,
+		config.KeyDistHotspot,
+	} {
+		n := int64(10)
+		c, err := New(testConfig(dist, 10), func() int64 { return n })
+		if err != nil {
+			t.Fatal(err)
+		}
+		histogram(t, c, 10)
+		n = 20
+		counts := histogram(t, c, 20)
+		var above int
+		for id := int64(11); id <= 20; id++ {
+			above += counts[id]
+		}
+		if above == 0 {
+			t.Errorf("%s: no id above the first 10 drawn after the items grew to 20", dist)
+		}
+	}
+}
+
+func TestLatestFavoursNewest(t *testing.T) {
+	tests := []struct {
+		n     int64
+		theta float64
+	}{
+		{100, 0.99},
+		{1000, 0.99},
+		{1000, 0.5},
+	}
+	for _, tt := range tests {
+		cfg := testConfig(config.KeyDistLatest, tt.n)
+		cfg.ZipfianTheta = tt.theta
+		c, err := New(cfg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := histogram(t, c, tt.n)
+		want := 1 / zeta(tt.n, tt.theta)
+		if got := float64(counts[tt.n]) / draws; math.Abs(got-want) > 0.1*want {
+			t.Errorf("n=%d theta=%g: newest id drawn %.4f of the time, want %.4f", tt.n, tt.theta, got, want)
+		}
+		if counts[tt.n] <= counts[tt.n-9] || counts[tt.n-9] <= counts[1] {
+			t.Errorf("n=%d theta=%g: counts of ids n, n-9, 1 are %d, %d, %d, want decreasing",
+				tt.n, tt.theta, counts[tt.n], counts[tt.n-9], counts[1])
+		}
+	}
+}
+
+func TestLatestFollowsInserts(t *testing.T) {
+	n := int64(100)
+	c, err := New(testConfig(config.KeyDistLatest, 100), func() int64 { return n })
+	if err != nil {
+		t.Fatal(err)
+	}
+	n = 150
+	counts := histogram(t, c, 150)
+	if counts[150] <= counts[100] {
+		t.Errorf("after inserts up to 150, id 150 drawn %d times and id 100 %d times", counts[150], counts[100])
+	}
+}
+
+func TestScrambledSpreadsHotIDs(t *testing.T) {
+	const n = 10000
+	c, err := New(testConfig(config.KeyDistScrambled, n), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := histogram(t, c, n)
+
+	// The hottest id takes rank 0's share, 1/zeta(n), like plain zipfian,
+	// but it and the other hot ids are no longer the lowest ones.
+	hottest, most := int64(0), 0
+	for id, k := range counts {
+		if k > most {
+			hottest, most = id, k
+		}
+	}
+	if want := 1 / zeta(n, 0.99); math.Abs(float64(most)/draws-want) > 0.1*want {
+		t.Errorf("hottest id drawn %.4f of the time, want %.4f", float64(most)/draws, want)
+	}
+	if hottest != 1+fnvHash64(0)%n {
+		t.Errorf("hottest id is %d, want rank 0 hashed to %d", hottest, 1+fnvHash64(0)%n)
+	}
+	var low int
+	for id := int64(1); id <= 10; id++ {
+		low += counts[id]
+	}
+	if float64(low)/draws > 0.05 {
+		t.Errorf("ids 1..10 drawn %.3f of the time, want the hot ids spread out", float64(low)/draws)
+	}
+}
+
+func TestFNVHash64(t *testing.T) {
+	// FNV-1a 64 of the 8 little-endian bytes of v, sign bit cleared, as
+	// YCSB's Utils.fnvhash64 computes it.
+	tests := []struct {
+		v    int64
+		want int64
+	}{
+		{0, 0x28c7f832281a39c5},
+		{1, 0x09cd31291d2aefa4},
+		{2, 0x66bd86443df8ce07},
+		{1000, 0x2d6323825fa766dc},
+	}
+	for _, tt := range tests {
+		if got := fnvHash64(tt.v); got != tt.want {
+			t.Errorf("fnvHash64(%d) = %#x, want %#x", tt.v, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package metrics
+
+// CAS summarizes the compare-and-set operations of a run. An operation
+// makes up to 1+retries attempts; an attempt is lost when another writer
+// changed the value first or the engine reported a write conflict.
+type CAS struct {
+	Ops      int64 `json:"ops"`
+	Applied  int64 `json:"applied"`
+	Attempts int64 `json:"attempts"`
+	// Exhausted counts operations that lost every attempt.
+	Exhausted int64 `json:"exhausted"`
+
+	// SuccessRate is the fraction of operations applied, ConflictRate the
+	// fraction of attempts lost, RetryRate the retries per operation.
+	SuccessRate  float64 `json:"success_rate"`
+	ConflictRate float64 `json:"conflict_rate"`
+	RetryRate    float64 `json:"retry_rate"`
+}
+
+type casCounts struct {
+	ops, applied, attempts int64
+}
+
+// RecordCAS accounts for one compare-and-set operation that took attempts
+// tries and applied or gave up.
+func (r *Recorder) RecordCAS(attempts int, applied bool) {
+	r.cas.ops++
+	r.cas.attempts += int64(attempts)
+	if applied {
+		r.cas.applied++
+	}
+}
+
+func (c casCounts) merge(other casCounts) casCounts {
+	return casCounts{
+		ops:      c.ops + other.ops,
+		applied:  c.applied + other.applied,
+		attempts: c.attempts + other.attempts,
+	}
+}
+
+func (c casCounts) summary() *CAS {
+	if c.ops == 0 {
+		return nil
+	}
+	s := &CAS{
+		Ops:         c.ops,
+		Applied:     c.applied,
+		Attempts:    c.attempts,
+		Exhausted:   c.ops - c.applied,
+		SuccessRate: float64(c.applied) / float64(c.ops),
+		RetryRate:   float64(c.attempts-c.ops) / float64(c.ops),
+	}
+	if c.attempts > 0 {
+		s.ConflictRate = float64(c.attempts-c.applied) / float64(c.attempts)
+	}
+	return s
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCASSummary(t *testing.T) {
+	type op struct {
+		attempts int
+		applied  bool
+	}
+	tests := []struct {
+		name string
+		ops  []op
+		want *CAS
+	}{
+		{"none", nil, nil},
+		{"all applied first try", []op{{1, true}, {1, true}},
+			&CAS{Ops: 2, Applied: 2, Attempts: 2, SuccessRate: 1}},
+		{"applied after lost attempts", []op{{3, true}, {1, true}},
+			&CAS{Ops: 2, Applied: 2, Attempts: 4, SuccessRate: 1, ConflictRate: 0.5, RetryRate: 1}},
+		{"exhausted", []op{{4, false}, {2, true}, {1, true}, {1, false}},
+			&CAS{Ops: 4, Applied: 2, Attempts: 8, Exhausted: 2, SuccessRate: 0.5, ConflictRate: 0.75, RetryRate: 1}},
+	}
+	for _, tt := range tests {
+		// Split the ops over two recorders to cover Merge.
+		r, other := NewRecorder(), NewRecorder()
+		for i, o := range tt.ops {
+			rec := r
+			if i%2 == 1 {
+				rec = other
+			}
+			rec.RecordCAS(o.attempts, o.applied)
+		}
+		r.Merge(other)
+		got := r.cas.summary()
+		if (got == nil) != (tt.want == nil) {
+			t.Fatalf("%s: summary %+v, want %+v", tt.name, got, tt.want)
+		}
+		if got == nil {
+			continue
+		}
+		if got.Ops != tt.want.Ops || got.Applied != tt.want.Applied || got.Attempts != tt.want.Attempts || got.Exhausted != tt.want.Exhausted {
+			t.Errorf("%s: counts %+v, want %+v", tt.name, *got, *tt.want)
+		}
+		for _, rate := range []struct {
+			name      string
+			got, want float64
+		}{
+			{"success", got.SuccessRate, tt.want.SuccessRate},
+			{"conflict", got.ConflictRate, tt.want.ConflictRate},
+			{"retry", got.RetryRate, tt.want.RetryRate},
+		} {
+			if math.Abs(rate.got-rate.want) > 1e-9 {
+				t.Errorf("%s: %s rate %g, want %g", tt.name, rate.name, rate.got, rate.want)
+			}
+		}
+	}
+}
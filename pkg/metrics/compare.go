@@ -0,0 +1,37 @@
+package metrics
+
+// Comparison lines up several results against the first one.
+type Comparison struct {
+	// Files and Names identify the results, baseline first.
+	Files []string `json:"files"`
+	Names []string `json:"names"`
+	// Runs counts the runs behind each result: 1, or the repeats of a
+	// --repeat result, whose values are then means.
+	Runs []int `json:"runs"`
+	// Mismatches lists how each result differs from the baseline in what
+	// it measured, such as the database or the key distribution.
+	Mismatches []string `json:"mismatches,omitempty"`
+	// Alpha is the significance level of the Welch tests.
+	Alpha float64 `json:"alpha"`
+	Rows  []Row   `json:"rows"`
+	// Regressions lists the threshold checks that failed; it is empty when
+	// every result is within the limits.
+	Regressions []string `json:"regressions,omitempty"`
+}
+
+// Row is one metric across the compared results. Delta and DeltaPct hold
+// the change of each result from the baseline, so their first entry is 0.
+// DeltaPct is null when the baseline value is 0, as no change from 0 has a
+// percentage.
+type Row struct {
+	Metric string `json:"metric"`
+	// HigherIsBetter tells how to read a delta: true for throughput,
+	// false for latency and errors.
+	HigherIsBetter bool       `json:"higher_is_better"`
+	Values         []float64  `json:"values"`
+	Delta          []float64  `json:"delta"`
+	DeltaPct       []*float64 `json:"delta_pct"`
+	// Welch holds the test of each result against the baseline; entries
+	// are null unless both sides have at least two runs.
+	Welch []*Welch `json:"welch,omitempty"`
+}
@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// Interval is one periodic report taken while a run is in progress.
+type Interval struct {
+	ElapsedSec float64 `json:"elapsed_sec"`
+
+	Ops    int64 `json:"ops"`
+	Errors int64 `json:"errors"`
+
+	QPS          float64 `json:"qps"`
+	ErrorsPerSec float64 `json:"errors_per_sec"`
+
+	P50Ms float64 `json:"p50_ms"`
+	P95Ms float64 `json:"p95_ms"`
+	P99Ms float64 `json:"p99_ms"`
+}
+
+// Window accumulates one worker's operations for the current interval.
+// Record is called by the owning worker and the collector swaps the
+// histogram out at each interval, so the mutex is per worker and only
+// contended at interval boundaries.
+type Window struct {
+	mu     sync.Mutex
+	h      *hdrhistogram.Histogram
+	ops    int64
+	errors int64
+}
+
+func (w *Window) Record(d time.Duration, ok bool) {
+	w.mu.Lock()
+	_ = w.h.RecordValue(durationUs(d))
+	w.ops++
+	if !ok {
+		w.errors++
+	}
+	w.mu.Unlock()
+}
+
+// swap installs an empty histogram and returns the filled one with its
+// counters.
+func (w *Window) swap(empty *hdrhistogram.Histogram) (*hdrhistogram.Histogram, int64, int64) {
+	w.mu.Lock()
+	h, ops, errors := w.h, w.ops, w.errors
+	w.h, w.ops, w.errors = empty, 0, 0
+	w.mu.Unlock()
+	return h, ops, errors
+}
+
+// IntervalCollector turns per-worker windows into a series of Intervals.
+// Collect must be called from a single goroutine.
+type IntervalCollector struct {
+	windows []*Window
+
+	start time.Time
+	last  time.Time
+
+	acc   *hdrhistogram.Histogram
+	spare *hdrhistogram.Histogram
+
+	intervals []Interval
+}
+
+func NewIntervalCollector(workers int, start time.Time) *IntervalCollector {
+	c := &IntervalCollector{
+		windows: make([]*Window, workers),
+		start:   start,
+		last:    start,
+		acc:     newHistogram(),
+		spare:   newHistogram(),
+	}
+	for i := range c.windows {
+		c.windows[i] = &Window{h: newHistogram()}
+	}
+	return c
+}
+
+// Window returns the window owned by worker i.
+func (c *IntervalCollector) Window(i int) *Window { return c.windows[i] }
+
+// Collect closes the interval ending at now and returns it.
+func (c *IntervalCollector) Collect(now time.Time) Interval {
+	c.acc.Reset()
+	var ops, errors int64
+	for _, w := range c.windows {
+		h, n, e := w.swap(c.spare)
+		c.acc.Merge(h)
+		h.Reset()
+		c.spare = h
+		ops += n
+		errors += e
+	}
+
+	secs := now.Sub(c.last).Seconds()
+	if secs <= 0 {
+		secs = 1e-9
+	}
+	c.last = now
+
+	q := func(p float64) float64 {
+		return float64(c.acc.ValueAtQuantile(p)) / 1000.0
+	}
+	iv := Interval{
+		ElapsedSec:   now.Sub(c.start).Seconds(),
+		Ops:          ops,
+		Errors:       errors,
+		QPS:          float64(ops) / secs,
+		ErrorsPerSec: float64(errors) / secs,
+		P50Ms:        q(50),
+		P95Ms:        q(95),
+		P99Ms:        q(99),
+	}
+	c.intervals = append(c.intervals, iv)
+	return iv
+}
+
+// Intervals returns every interval collected so far.
+func (c *IntervalCollector) Intervals() []Interval { return c.intervals }
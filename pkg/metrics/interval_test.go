@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestIntervalCollector(t *testing.T) {
+	// Each step records ops on the windows and then collects at an offset
+	// from the start; the last interval is a partial one, as when a run
+	// ends between two reports.
+	type op struct {
+		worker  int
+		latency time.Duration
+		ok      bool
+	}
+	tests := []struct {
+		at      time.Duration
+		ops     []op
+		wantOps int64
+		errors  int64
+		qps     float64
+		p99Ms   float64
+	}{
+		{
+			at:      time.Second,
+			ops:     []op{{0, time.Millisecond, true}, {1, time.Millisecond, true}, {1, 2 * time.Millisecond, false}},
+			wantOps: 3, errors: 1, qps: 3, p99Ms: 2,
+		},
+		{
+			// Nothing from the first interval carries over.
+			at:      2 * time.Second,
+			ops:     []op{{0, 50 * time.Millisecond, true}},
+			wantOps: 1, qps: 1, p99Ms: 50,
+		},
+		{
+			at: 3 * time.Second,
+		},
+		{
+			at:      3500 * time.Millisecond,
+			ops:     []op{{1, time.Millisecond, true}, {1, time.Millisecond, true}},
+			wantOps: 2, qps: 4, p99Ms: 1,
+		},
+	}
+	start := time.Unix(100, 0)
+	c := NewIntervalCollector(2, start)
+	for i, tt := range tests {
+		for _, o := range tt.ops {
+			c.Window(o.worker).Record(o.latency, o.ok)
+		}
+		iv := c.Collect(start.Add(tt.at))
+		if iv.ElapsedSec != tt.at.Seconds() {
+			t.Errorf("interval %d: elapsed %gs, want %gs", i, iv.ElapsedSec, tt.at.Seconds())
+		}
+		if iv.Ops != tt.wantOps || iv.Errors != tt.errors {
+			t.Errorf("interval %d: %d ops, %d errors, want %d, %d", i, iv.Ops, iv.Errors, tt.wantOps, tt.errors)
+		}
+		if math.Abs(iv.QPS-tt.qps) > 1e-9 {
+			t.Errorf("interval %d: qps %g, want %g", i, iv.QPS, tt.qps)
+		}
+		if math.Abs(iv.P99Ms-tt.p99Ms) > 0.01*tt.p99Ms {
+			t.Errorf("interval %d: p99 %gms, want %gms", i, iv.P99Ms, tt.p99Ms)
+		}
+	}
+	if got := len(c.Intervals()); got != len(tests) {
+		t.Errorf("%d intervals kept, want %d", got, len(tests))
+	}
+}
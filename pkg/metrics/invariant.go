@@ -0,0 +1,20 @@
+package metrics
+
+// Invariant records the checks of a conserved total, such as the sum of
+// balances in the bank workload.
+type Invariant struct {
+	Expected   int64       `json:"expected"`
+	Checks     int         `json:"checks"`
+	Violations []Violation `json:"violations,omitempty"`
+	// Snapshot tells whether the checks during the run read a consistent
+	// snapshot. Without one only the final check is conclusive.
+	Snapshot bool `json:"snapshot"`
+}
+
+// Violation is one check that found a total other than the expected one.
+type Violation struct {
+	ElapsedSec float64 `json:"elapsed_sec"`
+	Got        int64   `json:"got"`
+	Rows       int64   `json:"rows"`
+	Final      bool    `json:"final,omitempty"`
+}
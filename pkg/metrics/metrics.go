@@ -7,6 +7,22 @@ import (
 	"github.com/HdrHistogram/hdrhistogram-go"
 )
 
+// Op names an operation type for per-operation latency breakdowns.
+type Op string
+
+const (
+	OpRead            Op = "read"
+	OpUpdate          Op = "update"
+	OpInsert          Op = "insert"
+	OpScan            Op = "scan"
+	OpIndexLookup     Op = "index-lookup"
+	OpTransfer        Op = "transfer"
+	OpDelete          Op = "delete"
+	OpTransaction     Op = "transaction"
+	OpReadModifyWrite Op = "read-modify-write"
+	OpCAS             Op = "cas"
+)
+
 type Summary struct {
 	Name string `json:"name"`
 
@@ -18,6 +34,8 @@ type Summary struct {
 	Errors int64 `json:"errors"`
 	Bytes  int64 `json:"bytes"`
 
+	ErrorClasses map[string]int64 `json:"error_classes,omitempty"`
+
 	AvgMs  float64 `json:"avg_ms"`
 	P50Ms  float64 `json:"p50_ms"`
 	P95Ms  float64 `json:"p95_ms"`
@@ -26,33 +44,130 @@ type Summary struct {
 
 	QPS float64 `json:"qps"`
 	BPS float64 `json:"bytes_per_sec"`
+
+	// Queries counts the statements inside transactions. For transactional
+	// workloads every op is a transaction, so QPS is transactions per
+	// second and QueriesPerSec the rate of the statements in them.
+	Queries       int64   `json:"queries,omitempty"`
+	QueriesPerSec float64 `json:"queries_per_sec,omitempty"`
+
+	// Rows counts rows written by prepare, where one op may be a batch.
+	Rows       int64   `json:"rows,omitempty"`
+	RowsPerSec float64 `json:"rows_per_sec,omitempty"`
+
+	// Clamped counts latencies above MaxLatency, recorded as MaxLatency.
+	Clamped int64 `json:"clamped,omitempty"`
+
+	Settings map[string]string `json:"settings,omitempty"`
+
+	KeyDist       string             `json:"key_dist,omitempty"`
+	KeyDistParams map[string]float64 `json:"key_dist_params,omitempty"`
+
+	PerOp map[Op]OpSummary `json:"per_op,omitempty"`
+
+	// PerTable counts operations by table in multi-table runs.
+	PerTable map[string]int64 `json:"per_table,omitempty"`
+
+	// Invariant holds the balance checks of a bank run.
+	Invariant *Invariant `json:"invariant,omitempty"`
+
+	// CAS holds the outcomes of compare-and-set operations.
+	CAS *CAS `json:"cas,omitempty"`
+
+	// Retry holds the attempts of a run with --retry-attempts.
+	Retry *Retries `json:"retry,omitempty"`
+
+	// Verify holds the read checks of a --verify run.
+	Verify *Verification `json:"verify,omitempty"`
+
+	// TargetRate is the offered load of an open-loop run. Latencies above
+	// are then measured from intended start times; Uncorrected holds the
+	// same operations timed from when they were actually sent.
+	TargetRate  float64  `json:"target_rate,omitempty"`
+	Uncorrected *Latency `json:"uncorrected,omitempty"`
+
+	Intervals []Interval `json:"intervals,omitempty"`
+}
+
+// Latency is a latency distribution in milliseconds.
+type Latency struct {
+	AvgMs  float64 `json:"avg_ms"`
+	P50Ms  float64 `json:"p50_ms"`
+	P95Ms  float64 `json:"p95_ms"`
+	P99Ms  float64 `json:"p99_ms"`
+	P999Ms float64 `json:"p999_ms"`
+}
+
+// OpSummary is the slice of a Summary attributed to one operation type.
+type OpSummary struct {
+	Ops    int64 `json:"ops"`
+	Errors int64 `json:"errors"`
+	Bytes  int64 `json:"bytes"`
+
+	AvgMs  float64 `json:"avg_ms"`
+	P50Ms  float64 `json:"p50_ms"`
+	P95Ms  float64 `json:"p95_ms"`
+	P99Ms  float64 `json:"p99_ms"`
+	P999Ms float64 `json:"p999_ms"`
+
+	QPS float64 `json:"qps"`
+
+	// Rows counts the rows returned by scans and index lookups; RowsPerOp
+	// averages them over the successful ones, and Empty counts those that
+	// returned none.
+	Rows      int64   `json:"rows,omitempty"`
+	RowsPerOp float64 `json:"rows_per_op,omitempty"`
+	Empty     int64   `json:"empty,omitempty"`
+
+	Uncorrected *Latency `json:"uncorrected,omitempty"`
 }
 
 type Recorder struct {
 	h *hdrhistogram.Histogram
+	// uncorrected is only set for open-loop runs, where h is timed from
+	// intended start times.
+	uncorrected *hdrhistogram.Histogram
 
 	start time.Time
 	end   time.Time
 
-	ops    int64
-	errors int64
-	bytes  int64
+	ops     int64
+	errors  int64
+	bytes   int64
+	rows    int64
+	empty   int64
+	queries int64
+	clamped int64
+
+	errorClasses map[string]int64
+	tables       map[string]int64
+	cas          casCounts
+
+	perOp map[Op]*Recorder
 }
 
 func NewRecorder() *Recorder {
-	// 1us..60s, 3 significant figures.
-	return &Recorder{h: hdrhistogram.New(1, int64((60 * time.Second).Microseconds()), 3)}
+	return &Recorder{h: newHistogram()}
+}
+
+// MaxLatency is the highest latency the histograms track. A longer one,
+// as an open-loop run sees behind a stall, is recorded as MaxLatency and
+// counted as clamped rather than dropped.
+const MaxLatency = time.Hour
+
+func newHistogram() *hdrhistogram.Histogram {
+	// 1us..MaxLatency, 3 significant figures.
+	return hdrhistogram.New(1, MaxLatency.Microseconds(), 3)
 }
 
 func (r *Recorder) Start(t time.Time) { r.start = t }
 func (r *Recorder) End(t time.Time)   { r.end = t }
 
 func (r *Recorder) Record(d time.Duration, nbytes int, ok bool) {
-	us := d.Microseconds()
-	if us < 1 {
-		us = 1
+	_ = r.h.RecordValue(durationUs(d))
+	if d > MaxLatency {
+		r.clamped++
 	}
-	_ = r.h.RecordValue(us)
 	r.ops++
 	if !ok {
 		r.errors++
@@ -60,11 +175,108 @@ func (r *Recorder) Record(d time.Duration, nbytes int, ok bool) {
 	r.bytes += int64(nbytes)
 }
 
+// AddRows counts rows written by an operation.
+func (r *Recorder) AddRows(n int) { r.rows += int64(n) }
+
+// RecordRows counts the rows a successful op of kind op returned.
+func (r *Recorder) RecordRows(op Op, n int) {
+	rec := r.opRecorder(op)
+	rec.rows += int64(n)
+	if n == 0 {
+		rec.empty++
+	}
+}
+
+// AddQueries counts statements run inside a transaction op.
+func (r *Recorder) AddQueries(n int) { r.queries += int64(n) }
+
+// RecordErrorClass counts a failed operation under class. The failure
+// itself is recorded by Record.
+func (r *Recorder) RecordErrorClass(class string) {
+	if r.errorClasses == nil {
+		r.errorClasses = make(map[string]int64)
+	}
+	r.errorClasses[class]++
+}
+
+// CountTable counts an operation against table. The operation itself is
+// recorded by Record.
+func (r *Recorder) CountTable(table string) {
+	if r.tables == nil {
+		r.tables = make(map[string]int64)
+	}
+	r.tables[table]++
+}
+
+// RecordOp records into the totals and into the recorder for op.
+func (r *Recorder) RecordOp(op Op, d time.Duration, nbytes int, ok bool) {
+	r.Record(d, nbytes, ok)
+	r.opRecorder(op).Record(d, nbytes, ok)
+}
+
+// RecordOpIntended records an open-loop operation: latency is measured from
+// the intended start time and service from when the request was sent.
+func (r *Recorder) RecordOpIntended(op Op, latency, service time.Duration, nbytes int, ok bool) {
+	r.recordIntended(latency, service, nbytes, ok)
+	r.opRecorder(op).recordIntended(latency, service, nbytes, ok)
+}
+
+func (r *Recorder) recordIntended(latency, service time.Duration, nbytes int, ok bool) {
+	r.Record(latency, nbytes, ok)
+	if r.uncorrected == nil {
+		r.uncorrected = newHistogram()
+	}
+	_ = r.uncorrected.RecordValue(durationUs(service))
+}
+
+func (r *Recorder) opRecorder(op Op) *Recorder {
+	if r.perOp == nil {
+		r.perOp = make(map[Op]*Recorder)
+	}
+	rec, ok := r.perOp[op]
+	if !ok {
+		rec = NewRecorder()
+		r.perOp[op] = rec
+	}
+	return rec
+}
+
+// durationUs converts d for recording, clamped to [1us, MaxLatency].
+func durationUs(d time.Duration) int64 {
+	return min(max(d.Microseconds(), 1), MaxLatency.Microseconds())
+}
+
 func (r *Recorder) Merge(other *Recorder) {
 	r.h.Merge(other.h)
+	if other.uncorrected != nil {
+		if r.uncorrected == nil {
+			r.uncorrected = newHistogram()
+		}
+		r.uncorrected.Merge(other.uncorrected)
+	}
+	for op, rec := range other.perOp {
+		r.opRecorder(op).Merge(rec)
+	}
 	r.ops += other.ops
 	r.errors += other.errors
 	r.bytes += other.bytes
+	r.rows += other.rows
+	r.empty += other.empty
+	r.clamped += other.clamped
+	r.queries += other.queries
+	r.cas = r.cas.merge(other.cas)
+	for class, n := range other.errorClasses {
+		if r.errorClasses == nil {
+			r.errorClasses = make(map[string]int64)
+		}
+		r.errorClasses[class] += n
+	}
+	for table, n := range other.tables {
+		if r.tables == nil {
+			r.tables = make(map[string]int64)
+		}
+		r.tables[table] += n
+	}
 	if r.start.IsZero() || (!other.start.IsZero() && other.start.Before(r.start)) {
 		r.start = other.start
 	}
@@ -87,14 +299,18 @@ func (r *Recorder) Summary(name string) Summary {
 		dur = time.Nanosecond
 	}
 
-	avgUs := float64(r.h.Mean())
-	q := func(p float64) float64 {
-		return float64(r.h.ValueAtQuantile(p)) / 1000.0
-	}
-
+	lat := latencyOf(r.h)
 	qps := float64(r.ops) / dur.Seconds()
 	bps := float64(r.bytes) / dur.Seconds()
 
+	var perOp map[Op]OpSummary
+	if len(r.perOp) > 0 {
+		perOp = make(map[Op]OpSummary, len(r.perOp))
+		for op, rec := range r.perOp {
+			perOp[op] = rec.opSummary(dur)
+		}
+	}
+
 	return Summary{
 		Name:   name,
 		Start:  start,
@@ -103,13 +319,74 @@ func (r *Recorder) Summary(name string) Summary {
 		Ops:    r.ops,
 		Errors: r.errors,
 		Bytes:  r.bytes,
-		AvgMs:  avgUs / 1000.0,
+		AvgMs:  lat.AvgMs,
+		P50Ms:  lat.P50Ms,
+		P95Ms:  lat.P95Ms,
+		P99Ms:  lat.P99Ms,
+		P999Ms: lat.P999Ms,
+		QPS:    qps,
+		BPS:    bps,
+		PerOp:  perOp,
+
+		Queries:       r.queries,
+		QueriesPerSec: float64(r.queries) / dur.Seconds(),
+
+		Rows:       r.rows,
+		RowsPerSec: float64(r.rows) / dur.Seconds(),
+
+		Clamped: r.clamped,
+
+		ErrorClasses: r.errorClasses,
+		PerTable:     r.tables,
+		CAS:          r.cas.summary(),
+
+		Uncorrected: r.uncorrectedLatency(),
+	}
+}
+
+func (r *Recorder) opSummary(dur time.Duration) OpSummary {
+	lat := latencyOf(r.h)
+	var rowsPerOp float64
+	if ok := r.ops - r.errors; ok > 0 {
+		rowsPerOp = float64(r.rows) / float64(ok)
+	}
+	return OpSummary{
+		Ops:    r.ops,
+		Errors: r.errors,
+		Bytes:  r.bytes,
+		AvgMs:  lat.AvgMs,
+		P50Ms:  lat.P50Ms,
+		P95Ms:  lat.P95Ms,
+		P99Ms:  lat.P99Ms,
+		P999Ms: lat.P999Ms,
+		QPS:    float64(r.ops) / dur.Seconds(),
+
+		Rows:      r.rows,
+		RowsPerOp: rowsPerOp,
+		Empty:     r.empty,
+
+		Uncorrected: r.uncorrectedLatency(),
+	}
+}
+
+func (r *Recorder) uncorrectedLatency() *Latency {
+	if r.uncorrected == nil {
+		return nil
+	}
+	lat := latencyOf(r.uncorrected)
+	return &lat
+}
+
+func latencyOf(h *hdrhistogram.Histogram) Latency {
+	q := func(p float64) float64 {
+		return float64(h.ValueAtQuantile(p)) / 1000.0
+	}
+	return Latency{
+		AvgMs:  h.Mean() / 1000.0,
 		P50Ms:  q(50),
 		P95Ms:  q(95),
 		P99Ms:  q(99),
 		P999Ms: q(99.9),
-		QPS:    qps,
-		BPS:    bps,
 	}
 }
 
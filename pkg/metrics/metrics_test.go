@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDurationUs(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want int64
+	}{
+		{0, 1},
+		{-time.Second, 1},
+		{500 * time.Nanosecond, 1},
+		{time.Millisecond, 1000},
+		{90 * time.Second, 90e6},
+		{MaxLatency, MaxLatency.Microseconds()},
+		{3 * MaxLatency, MaxLatency.Microseconds()},
+	}
+	for _, tt := range tests {
+		if got := durationUs(tt.d); got != tt.want {
+			t.Errorf("durationUs(%s) = %d, want %d", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestRecorderClamps(t *testing.T) {
+	tests := []struct {
+		name      string
+		latencies []time.Duration
+		clamped   int64
+	}{
+		{"in range", []time.Duration{time.Millisecond, 90 * time.Second}, 0},
+		{"past the range", []time.Duration{time.Millisecond, 2 * time.Hour, 3 * time.Hour}, 2},
+	}
+	for _, tt := range tests {
+		r := NewRecorder()
+		start := time.Unix(0, 0)
+		r.Start(start)
+		for _, d := range tt.latencies {
+			r.Record(d, 0, true)
+		}
+		other := NewRecorder()
+		other.Record(2*MaxLatency, 0, true)
+		r.Merge(other)
+		r.End(start.Add(time.Minute))
+
+		s := r.Summary("test")
+		if s.Ops != int64(len(tt.latencies))+1 {
+			t.Errorf("%s: ops = %d, want %d; no latency may be dropped", tt.name, s.Ops, len(tt.latencies)+1)
+		}
+		if s.Clamped != tt.clamped+1 {
+			t.Errorf("%s: clamped = %d, want %d", tt.name, s.Clamped, tt.clamped+1)
+		}
+		if want := float64(MaxLatency.Milliseconds()); s.P999Ms < want*0.999 || s.P999Ms > want*1.001 {
+			t.Errorf("%s: p999 = %gms, want the clamped %gms", tt.name, s.P999Ms, want)
+		}
+	}
+}
+
+func TestRecordRows(t *testing.T) {
+	tests := []struct {
+		name      string
+		rows      []int
+		total     int64
+		empty     int64
+		rowsPerOp float64
+	}{
+		{"every lookup hits", []int{1, 1, 1, 1}, 4, 0, 1},
+		{"some lookups miss", []int{0, 2, 0, 3}, 5, 2, 1.25},
+		{"every lookup misses", []int{0, 0}, 0, 2, 0},
+	}
+	for _, tt := range tests {
+		r, other := NewRecorder(), NewRecorder()
+		start := time.Unix(0, 0)
+		r.Start(start)
+		for i, n := range tt.rows {
+			// Split the ops over two recorders to cover Merge.
+			rec := r
+			if i%2 == 1 {
+				rec = other
+			}
+			rec.RecordOp(OpIndexLookup, time.Millisecond, 0, true)
+			rec.RecordRows(OpIndexLookup, n)
+		}
+		r.Merge(other)
+		r.End(start.Add(time.Second))
+
+		o := r.Summary("test").PerOp[OpIndexLookup]
+		if o.Rows != tt.total || o.Empty != tt.empty || o.RowsPerOp != tt.rowsPerOp {
+			t.Errorf("%s: rows=%d empty=%d rows/op=%g, want %d, %d, %g",
+				tt.name, o.Rows, o.Empty, o.RowsPerOp, tt.total, tt.empty, tt.rowsPerOp)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import "time"
+
+// Repeated is the result of running one workload several times: the
+// spread of each headline metric and every run's own summary.
+type Repeated struct {
+	Name     string        `json:"name"`
+	Repeats  int           `json:"repeats"`
+	Cooldown time.Duration `json:"cooldown"`
+
+	QPS    Stat `json:"qps"`
+	AvgMs  Stat `json:"avg_ms"`
+	P50Ms  Stat `json:"p50_ms"`
+	P95Ms  Stat `json:"p95_ms"`
+	P99Ms  Stat `json:"p99_ms"`
+	P999Ms Stat `json:"p999_ms"`
+
+	Runs []Summary `json:"runs"`
+}
+
+// NewRepeated computes the statistics over runs.
+func NewRepeated(runs []Summary, cooldown time.Duration) Repeated {
+	r := Repeated{Repeats: len(runs), Cooldown: cooldown, Runs: runs}
+	if len(runs) > 0 {
+		r.Name = runs[0].Name
+	}
+	stat := func(get func(Summary) float64) Stat {
+		values := make([]float64, len(runs))
+		for i, s := range runs {
+			values[i] = get(s)
+		}
+		return NewStat(values)
+	}
+	r.QPS = stat(func(s Summary) float64 { return s.QPS })
+	r.AvgMs = stat(func(s Summary) float64 { return s.AvgMs })
+	r.P50Ms = stat(func(s Summary) float64 { return s.P50Ms })
+	r.P95Ms = stat(func(s Summary) float64 { return s.P95Ms })
+	r.P99Ms = stat(func(s Summary) float64 { return s.P99Ms })
+	r.P999Ms = stat(func(s Summary) float64 { return s.P999Ms })
+	return r
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// Retries summarizes the attempts behind the operations of a run with
+// --retry-attempts. The run's latencies are end to end, retries and
+// backoff included; Attempt times each try on its own.
+type Retries struct {
+	MaxAttempts int      `json:"max_attempts"`
+	On          []string `json:"on"`
+
+	Attempts int64 `json:"attempts"`
+	Retries  int64 `json:"retries"`
+	// GaveUp counts operations that failed with a retryable error on
+	// their last attempt.
+	GaveUp int64 `json:"gave_up"`
+	// ByClass counts retries by the error class that caused them.
+	ByClass map[string]int64 `json:"by_class,omitempty"`
+
+	Attempt Latency `json:"attempt"`
+}
+
+// RetryRecorder accumulates attempts for one worker. It is not safe for
+// concurrent use; merge per-worker recorders instead.
+type RetryRecorder struct {
+	attempt *hdrhistogram.Histogram
+
+	attempts, retries, gaveUp int64
+	byClass                   map[string]int64
+}
+
+func NewRetryRecorder() *RetryRecorder {
+	return &RetryRecorder{attempt: newHistogram(), byClass: make(map[string]int64)}
+}
+
+// RecordAttempt accounts for one try of an operation.
+func (r *RetryRecorder) RecordAttempt(d time.Duration) {
+	r.attempts++
+	_ = r.attempt.RecordValue(durationUs(d))
+}
+
+// RecordRetry accounts for a try that failed with class and is retried.
+func (r *RetryRecorder) RecordRetry(class string) {
+	r.retries++
+	r.byClass[class]++
+}
+
+// RecordGaveUp accounts for an operation that ran out of attempts.
+func (r *RetryRecorder) RecordGaveUp() { r.gaveUp++ }
+
+func (r *RetryRecorder) Merge(other *RetryRecorder) {
+	r.attempt.Merge(other.attempt)
+	r.attempts += other.attempts
+	r.retries += other.retries
+	r.gaveUp += other.gaveUp
+	for class, n := range other.byClass {
+		r.byClass[class] += n
+	}
+}
+
+func (r *RetryRecorder) Result(maxAttempts int, on []string) *Retries {
+	res := &Retries{
+		MaxAttempts: maxAttempts,
+		On:          on,
+		Attempts:    r.attempts,
+		Retries:     r.retries,
+		GaveUp:      r.gaveUp,
+		Attempt:     latencyOf(r.attempt),
+	}
+	if len(r.byClass) > 0 {
+		res.ByClass = r.byClass
+	}
+	return res
+}
@@ -0,0 +1,18 @@
+package metrics
+
+// Scenario is the combined result of a scenario file: one summary per
+// phase, in the order the phases ran.
+type Scenario struct {
+	Name   string  `json:"name"`
+	Phases []Phase `json:"phases"`
+}
+
+// Phase is the result of one scenario phase.
+type Phase struct {
+	Name    string  `json:"name"`
+	Kind    string  `json:"kind"`
+	Summary Summary `json:"summary"`
+	// Error is set when the phase failed; Summary then holds what it
+	// measured before failing.
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,31 @@
+package metrics
+
+// Search is the result of a max-throughput search under a p99 objective.
+type Search struct {
+	Name string `json:"name"`
+	// Param is the searched setting, "rate" or "threads".
+	Param    string  `json:"param"`
+	SLOP99Ms float64 `json:"slo_p99_ms"`
+	// Best is the highest value whose trial met the objective, and
+	// BestQPS the throughput that trial achieved; both are 0 when no trial
+	// passed.
+	Best    float64 `json:"best"`
+	BestQPS float64 `json:"best_qps"`
+	// Capped is set when the highest value tried passed, so the real
+	// maximum may lie above it.
+	Capped bool    `json:"capped,omitempty"`
+	Trials []Trial `json:"trials"`
+}
+
+// Trial is one short run of a search, in the order they ran.
+type Trial struct {
+	Value  float64 `json:"value"`
+	QPS    float64 `json:"qps"`
+	P99Ms  float64 `json:"p99_ms"`
+	Errors int64   `json:"errors"`
+	Pass   bool    `json:"pass"`
+	// Error is set when the run ended early, e.g. past --max-errors; the
+	// trial then fails.
+	Error   string  `json:"error,omitempty"`
+	Summary Summary `json:"summary"`
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import "math"
+
+// Stat describes one metric over repeated runs. StdDev is the sample
+// standard deviation and CI95 the 95% confidence interval of the mean
+// from Student's t distribution; with one run both collapse to the value.
+type Stat struct {
+	N        int     `json:"n"`
+	Mean     float64 `json:"mean"`
+	StdDev   float64 `json:"stddev"`
+	CI95Low  float64 `json:"ci95_low"`
+	CI95High float64 `json:"ci95_high"`
+}
+
+func NewStat(values []float64) Stat {
+	n := len(values)
+	if n == 0 {
+		return Stat{}
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(n)
+	s := Stat{N: n, Mean: mean, CI95Low: mean, CI95High: mean}
+	if n < 2 {
+		return s
+	}
+	var ss float64
+	for _, v := range values {
+		ss += (v - mean) * (v - mean)
+	}
+	s.StdDev = math.Sqrt(ss / float64(n-1))
+	half := tQuantile(0.05, float64(n-1)) * s.StdDev / math.Sqrt(float64(n))
+	s.CI95Low, s.CI95High = mean-half, mean+half
+	return s
+}
+
+// Welch is the outcome of Welch's t-test between two sets of runs.
+type Welch struct {
+	T  float64 `json:"t"`
+	DF float64 `json:"df"`
+	// P is the two-sided p-value of the means being equal.
+	P float64 `json:"p"`
+}
+
+// WelchTest compares the means of a and b without assuming equal
+// variances. ok is false when either side has fewer than two values or
+// neither side has any spread, as with an error count that stays at 0.
+func WelchTest(a, b []float64) (w Welch, ok bool) {
+	if len(a) < 2 || len(b) < 2 {
+		return Welch{}, false
+	}
+	sa, sb := NewStat(a), NewStat(b)
+	va := sa.StdDev * sa.StdDev / float64(sa.N)
+	vb := sb.StdDev * sb.StdDev / float64(sb.N)
+	se2 := va + vb
+	if se2 == 0 {
+		return Welch{}, false
+	}
+	w.T = (sb.Mean - sa.Mean) / math.Sqrt(se2)
+	w.DF = se2 * se2 / (va*va/float64(sa.N-1) + vb*vb/float64(sb.N-1))
+	w.P = tTwoSided(w.T, w.DF)
+	return w, true
+}
+
+// tTwoSided returns P(|T| >= |t|) for Student's t with df degrees of
+// freedom.
+func tTwoSided(t, df float64) float64 {
+	return incBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// tQuantile returns the t with P(|T| >= t) = p, by bisection.
+func tQuantile(p, df float64) float64 {
+	lo, hi := 0.0, 1e6
+	for i := 0; i < 200; i++ {
+		mid := (lo + hi) / 2
+		if tTwoSided(mid, df) > p {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// incBeta is the regularized incomplete beta function I_x(a, b).
+func incBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	if x < (a+1)/(a+b+2) {
+		return front * betaFraction(a, b, x) / a
+	}
+	return 1 - front*betaFraction(b, a, 1-x)/b
+}
+
+// betaFraction evaluates the continued fraction of incBeta with Lentz's
+// method.
+func betaFraction(a, b, x float64) float64 {
+	const (
+		tiny = 1e-300
+		eps  = 1e-15
+	)
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= 300; m++ {
+		fm := float64(m)
+		num := fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm))
+		for i := 0; i < 2; i++ {
+			d = 1 + num*d
+			if math.Abs(d) < tiny {
+				d = tiny
+			}
+			c = 1 + num/c
+			if math.Abs(c) < tiny {
+				c = tiny
+			}
+			d = 1 / d
+			h *= d * c
+			num = -(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1))
+		}
+		if math.Abs(d*c-1) < eps {
+			break
+		}
+	}
+	return h
+}
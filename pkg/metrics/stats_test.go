@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+func near(got, want, tol float64) bool {
+	return math.Abs(got-want) <= tol
+}
+
+func TestNewStat(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []float64
+		mean, sd float64
+		// t is the 97.5% quantile of Student's t with n-1 degrees of
+		// freedom, from a table.
+		t float64
+	}{
+		{"empty", nil, 0, 0, 0},
+		{"one", []float64{42}, 42, 0, 0},
+		{"two", []float64{1, 3}, 2, math.Sqrt2, 12.706205},
+		{"five", []float64{1, 2, 3, 4, 5}, 3, math.Sqrt(2.5), 2.776445},
+		{"eight", []float64{2, 4, 4, 4, 5, 5, 7, 9}, 5, math.Sqrt(32.0 / 7), 2.364624},
+		{"constant", []float64{7, 7, 7}, 7, 0, 4.302653},
+	}
+	for _, tt := range tests {
+		s := NewStat(tt.values)
+		if s.N != len(tt.values) || !near(s.Mean, tt.mean, 1e-12) || !near(s.StdDev, tt.sd, 1e-12) {
+			t.Errorf("%s: NewStat() = %+v, want n=%d mean=%g stddev=%g", tt.name, s, len(tt.values), tt.mean, tt.sd)
+			continue
+		}
+		half := 0.0
+		if len(tt.values) > 1 {
+			half = tt.t * tt.sd / math.Sqrt(float64(len(tt.values)))
+		}
+		if !near(s.CI95Low, tt.mean-half, 1e-5) || !near(s.CI95High, tt.mean+half, 1e-5) {
+			t.Errorf("%s: CI95 = [%g, %g], want [%g, %g]", tt.name, s.CI95Low, s.CI95High, tt.mean-half, tt.mean+half)
+		}
+	}
+}
+
+func TestWelchTest(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      []float64
+		tStat, df float64
+		p         float64
+		ok        bool
+	}{
+		{
+			name: "equal variances",
+			a:    []float64{1, 2, 3, 4, 5}, b: []float64{3, 4, 5, 6, 7},
+			tStat: 2, df: 8, p: 0.0805162, ok: true,
+		},
+		{
+			name: "unequal sizes and variances",
+			a:    []float64{1, 2, 3, 4, 5}, b: []float64{2, 4, 6, 8, 10, 12},
+			tStat: 2.3763541, df: 6.9722557, p: 0.0492843, ok: true,
+		},
+		{
+			name: "same samples",
+			a:    []float64{1, 2, 3}, b: []float64{1, 2, 3},
+			tStat: 0, df: 4, p: 1, ok: true,
+		},
+		{name: "too few runs", a: []float64{1}, b: []float64{1, 2, 3}},
+		{name: "no spread", a: []float64{0, 0, 0}, b: []float64{0, 0}},
+	}
+	for _, tt := range tests {
+		w, ok := WelchTest(tt.a, tt.b)
+		if ok != tt.ok {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if !near(w.T, tt.tStat, 1e-6) || !near(w.DF, tt.df, 1e-6) || !near(w.P, tt.p, 1e-6) {
+			t.Errorf("%s: WelchTest() = %+v, want t=%g df=%g p=%g", tt.name, w, tt.tStat, tt.df, tt.p)
+		}
+	}
+}
+
+func TestIncBeta(t *testing.T) {
+	tests := []struct {
+		a, b, x float64
+		want    float64
+	}{
+		{1, 1, 0.3, 0.3},
+		{3, 1, 0.5, 0.125},
+		{1, 4, 0.2, 1 - math.Pow(0.8, 4)},
+		{2, 2, 0.5, 0.5},
+		{7.5, 7.5, 0.5, 0.5},
+		{0.5, 0.5, 0.25, 2 / math.Pi * math.Asin(0.5)},
+		{0.5, 0.5, 0.9, 2 / math.Pi * math.Asin(math.Sqrt(0.9))},
+		// I_x(2, 3) = 6x^2 - 8x^3 + 3x^4.
+		{2, 3, 0.4, 6*0.16 - 8*0.064 + 3*0.0256},
+		{2, 3, 0, 0},
+		{2, 3, 1, 1},
+		{2, 3, -1, 0},
+		{2, 3, 2, 1},
+	}
+	for _, tt := range tests {
+		if got := incBeta(tt.a, tt.b, tt.x); !near(got, tt.want, 1e-12) {
+			t.Errorf("incBeta(%g, %g, %g) = %.15g, want %.15g", tt.a, tt.b, tt.x, got, tt.want)
+		}
+	}
+}
+
+func TestTTwoSided(t *testing.T) {
+	tests := []struct {
+		t, df float64
+		want  float64
+	}{
+		{0, 5, 1},
+		// df 1 is the Cauchy distribution and df 2 has a closed form.
+		{1, 1, 0.5},
+		{3, 1, 1 - 2/math.Pi*math.Atan(3)},
+		{2, 2, 1 - 2/math.Sqrt(6)},
+		{-2, 2, 1 - 2/math.Sqrt(6)},
+		{2, 8, 0.0805162},
+		{1.5, 3.5, 0.2178182},
+	}
+	for _, tt := range tests {
+		if got := tTwoSided(tt.t, tt.df); !near(got, tt.want, 1e-6) {
+			t.Errorf("tTwoSided(%g, %g) = %.7f, want %.7f", tt.t, tt.df, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package metrics
+
+// Sweep is the result of running one workload at a series of thread
+// counts or target rates.
+type Sweep struct {
+	Name string `json:"name"`
+	// Param is the swept setting, "threads" or "rate".
+	Param string      `json:"param"`
+	Steps []SweepStep `json:"steps"`
+	// KneeReason says why the step marked Knee was picked; it is empty
+	// when no step was marked.
+	KneeReason string `json:"knee_reason,omitempty"`
+}
+
+// SweepStep is one run of a sweep.
+type SweepStep struct {
+	Value  float64 `json:"value"`
+	QPS    float64 `json:"qps"`
+	P99Ms  float64 `json:"p99_ms"`
+	AvgMs  float64 `json:"avg_ms"`
+	Errors int64   `json:"errors"`
+	// Knee marks the last step before throughput stopped growing or p99
+	// crossed the threshold.
+	Knee    bool    `json:"knee,omitempty"`
+	Summary Summary `json:"summary"`
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// Verification summarizes the reads checked in verification mode.
+type Verification struct {
+	// Level is the read consistency the run used, e.g. a Cassandra
+	// consistency level or TiDB replica read setting.
+	Level string `json:"level"`
+
+	Reads      int64 `json:"reads"`
+	StaleReads int64 `json:"stale_reads"`
+
+	ReadYourWritesViolations int64 `json:"read_your_writes_violations"`
+	MonotonicReadViolations  int64 `json:"monotonic_read_violations"`
+
+	// Staleness is a lower bound on how long the value a stale read
+	// returned had already been overwritten, over stale reads only.
+	Staleness *Latency `json:"staleness,omitempty"`
+}
+
+// VerifyRecorder accumulates read checks for one worker. It is not safe
+// for concurrent use; merge per-worker recorders instead.
+type VerifyRecorder struct {
+	staleness *hdrhistogram.Histogram
+
+	reads, stale, ryw, monotonic int64
+}
+
+func NewVerifyRecorder() *VerifyRecorder {
+	return &VerifyRecorder{staleness: newHistogram()}
+}
+
+// RecordRead accounts for one checked read. staleness is only used when
+// stale is set.
+func (v *VerifyRecorder) RecordRead(stale bool, staleness time.Duration, ryw, monotonic bool) {
+	v.reads++
+	if stale {
+		v.stale++
+		_ = v.staleness.RecordValue(durationUs(staleness))
+	}
+	if ryw {
+		v.ryw++
+	}
+	if monotonic {
+		v.monotonic++
+	}
+}
+
+func (v *VerifyRecorder) Merge(other *VerifyRecorder) {
+	v.staleness.Merge(other.staleness)
+	v.reads += other.reads
+	v.stale += other.stale
+	v.ryw += other.ryw
+	v.monotonic += other.monotonic
+}
+
+func (v *VerifyRecorder) Result(level string) *Verification {
+	res := &Verification{
+		Level:                    level,
+		Reads:                    v.reads,
+		StaleReads:               v.stale,
+		ReadYourWritesViolations: v.ryw,
+		MonotonicReadViolations:  v.monotonic,
+	}
+	if v.stale > 0 {
+		lat := latencyOf(v.staleness)
+		res.Staleness = &lat
+	}
+	return res
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"tidb-benchmarks/pkg/config"
@@ -20,13 +22,381 @@ func Print(format config.OutputFormat, s metrics.Summary) error {
 		fmt.Printf("Name: %s\n", s.Name)
 		fmt.Printf("Duration: %s\n", s.Dur.Round(time.Millisecond))
 		fmt.Printf("Ops: %d\n", s.Ops)
-		fmt.Printf("Errors: %d\n", s.Errors)
+		fmt.Printf("Errors: %d%s\n", s.Errors, formatCounts(s.ErrorClasses))
 		fmt.Printf("Bytes: %d\n", s.Bytes)
+		if s.Rows > 0 {
+			fmt.Printf("Rows: %d (%.2f rows/sec)\n", s.Rows, s.RowsPerSec)
+		}
 		fmt.Printf("QPS: %.2f\n", s.QPS)
+		if s.Queries > 0 {
+			fmt.Printf("Queries: %d (%.2f queries/sec)\n", s.Queries, s.QueriesPerSec)
+		}
 		fmt.Printf("BPS: %.2f\n", s.BPS)
+		if s.TargetRate > 0 {
+			fmt.Printf("Target rate: %.2f ops/sec (open loop)\n", s.TargetRate)
+		}
 		fmt.Printf("Latency(ms): avg=%.3f p50=%.3f p95=%.3f p99=%.3f p999=%.3f\n", s.AvgMs, s.P50Ms, s.P95Ms, s.P99Ms, s.P999Ms)
+		if u := s.Uncorrected; u != nil {
+			fmt.Printf("Latency(ms, uncorrected): avg=%.3f p50=%.3f p95=%.3f p99=%.3f p999=%.3f\n", u.AvgMs, u.P50Ms, u.P95Ms, u.P99Ms, u.P999Ms)
+		}
+		if s.Clamped > 0 {
+			fmt.Printf("Clamped: %d latencies above %s recorded as %s\n", s.Clamped, metrics.MaxLatency, metrics.MaxLatency)
+		}
+		if len(s.PerOp) > 0 {
+			fmt.Println("Per operation:")
+			ops := make([]string, 0, len(s.PerOp))
+			for op := range s.PerOp {
+				ops = append(ops, string(op))
+			}
+			sort.Strings(ops)
+			for _, op := range ops {
+				o := s.PerOp[metrics.Op(op)]
+				fmt.Printf("  %-18s ops=%d errors=%d qps=%.2f latency(ms): avg=%.3f p50=%.3f p95=%.3f p99=%.3f p999=%.3f\n",
+					op, o.Ops, o.Errors, o.QPS, o.AvgMs, o.P50Ms, o.P95Ms, o.P99Ms, o.P999Ms)
+				if o.Rows > 0 || o.Empty > 0 {
+					fmt.Printf("  %-18s rows=%d rows/op=%.1f empty=%d\n", "", o.Rows, o.RowsPerOp, o.Empty)
+				}
+				if u := o.Uncorrected; u != nil {
+					fmt.Printf("  %-18s uncorrected latency(ms): avg=%.3f p50=%.3f p95=%.3f p99=%.3f p999=%.3f\n",
+						"", u.AvgMs, u.P50Ms, u.P95Ms, u.P99Ms, u.P999Ms)
+				}
+			}
+		}
+		if len(s.PerTable) > 0 {
+			fmt.Printf("Per table ops:%s\n", formatTables(s.PerTable))
+		}
+		if len(s.Settings) > 0 {
+			fmt.Printf("Settings:%s\n", formatSettings(s.Settings))
+		}
+		if s.KeyDist != "" {
+			fmt.Printf("Key distribution: %s%s\n", s.KeyDist, formatParams(s.KeyDistParams))
+		}
+		if c := s.CAS; c != nil {
+			fmt.Printf("CAS: ops=%d applied=%d exhausted=%d attempts=%d success=%.2f%% conflict=%.2f%% retries/op=%.3f\n",
+				c.Ops, c.Applied, c.Exhausted, c.Attempts, 100*c.SuccessRate, 100*c.ConflictRate, c.RetryRate)
+		}
+		if rt := s.Retry; rt != nil {
+			fmt.Printf("Retries: max-attempts=%d on=%s attempts=%d retries=%d gave-up=%d%s\n",
+				rt.MaxAttempts, strings.Join(rt.On, ","), rt.Attempts, rt.Retries, rt.GaveUp, formatCounts(rt.ByClass))
+			fmt.Printf("Attempt latency(ms): avg=%.3f p50=%.3f p95=%.3f p99=%.3f p999=%.3f\n",
+				rt.Attempt.AvgMs, rt.Attempt.P50Ms, rt.Attempt.P95Ms, rt.Attempt.P99Ms, rt.Attempt.P999Ms)
+		}
+		if v := s.Verify; v != nil {
+			fmt.Printf("Verify (%s): reads=%d stale=%d (%.2f%%) read-your-writes violations=%d monotonic-read violations=%d\n",
+				v.Level, v.Reads, v.StaleReads, percent(v.StaleReads, v.Reads), v.ReadYourWritesViolations, v.MonotonicReadViolations)
+			if st := v.Staleness; st != nil {
+				fmt.Printf("Staleness(ms): avg=%.3f p50=%.3f p95=%.3f p99=%.3f p999=%.3f\n", st.AvgMs, st.P50Ms, st.P95Ms, st.P99Ms, st.P999Ms)
+			}
+		}
+		if inv := s.Invariant; inv != nil {
+			snapshot := ""
+			if !inv.Snapshot {
+				snapshot = ", checks during the run are not snapshots"
+			}
+			fmt.Printf("Invariant: expected total=%d checks=%d violations=%d%s\n", inv.Expected, inv.Checks, len(inv.Violations), snapshot)
+			for _, v := range inv.Violations {
+				final := ""
+				if v.Final {
+					final = " (final)"
+				}
+				elapsed := time.Duration(v.ElapsedSec * float64(time.Second)).Round(time.Millisecond)
+				fmt.Printf("  [ %s ] total=%d rows=%d diff=%+d%s\n", elapsed, v.Got, v.Rows, v.Got-inv.Expected, final)
+			}
+		}
 		return nil
 	default:
 		return fmt.Errorf("unsupported output format: %s", format)
 	}
 }
+
+// PrintScenario prints every phase of a scenario run, as one JSON object
+// or as the text report of each phase under a header.
+func PrintScenario(format config.OutputFormat, sc metrics.Scenario) error {
+	switch format {
+	case config.OutputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(sc)
+	case config.OutputText, "":
+		fmt.Printf("Scenario: %s (%d phases)\n", sc.Name, len(sc.Phases))
+		for i, p := range sc.Phases {
+			fmt.Printf("\n== Phase %d/%d: %s (%s) ==\n", i+1, len(sc.Phases), p.Name, p.Kind)
+			if err := Print(format, p.Summary); err != nil {
+				return err
+			}
+			if p.Error != "" {
+				fmt.Printf("Failed: %s\n", p.Error)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+// PrintSweep prints the QPS and p99 of every sweep step, marking the knee;
+// JSON output also carries each step's full summary.
+func PrintSweep(format config.OutputFormat, sw metrics.Sweep) error {
+	switch format {
+	case config.OutputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(sw)
+	case config.OutputText, "":
+		fmt.Printf("Sweep: %s over %s\n", sw.Name, sw.Param)
+		fmt.Printf("%10s %12s %10s %10s %8s\n", sw.Param, "qps", "p99(ms)", "avg(ms)", "errors")
+		for _, st := range sw.Steps {
+			knee := ""
+			if st.Knee {
+				knee = "  <- knee"
+			}
+			fmt.Printf("%10g %12.2f %10.3f %10.3f %8d%s\n", st.Value, st.QPS, st.P99Ms, st.AvgMs, st.Errors, knee)
+		}
+		if sw.KneeReason != "" {
+			fmt.Printf("Knee: %s\n", sw.KneeReason)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+// PrintSearch prints the outcome of a max-throughput search and every
+// trial in the order it ran.
+func PrintSearch(format config.OutputFormat, sr metrics.Search) error {
+	switch format {
+	case config.OutputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(sr)
+	case config.OutputText, "":
+		fmt.Printf("Search: %s, max %s with p99 <= %.3fms\n", sr.Name, sr.Param, sr.SLOP99Ms)
+		fmt.Printf("%6s %10s %12s %10s %8s  %s\n", "trial", sr.Param, "qps", "p99(ms)", "errors", "result")
+		for i, t := range sr.Trials {
+			result := "fail"
+			if t.Pass {
+				result = "pass"
+			}
+			if t.Error != "" {
+				result += " (" + t.Error + ")"
+			}
+			fmt.Printf("%6d %10g %12.2f %10.3f %8d  %s\n", i+1, t.Value, t.QPS, t.P99Ms, t.Errors, result)
+		}
+		switch {
+		case sr.Best == 0:
+			fmt.Println("Result: no trial met the objective")
+		case sr.Capped:
+			fmt.Printf("Result: %s=%g (%.2f qps), the highest value tried; the limit may be higher\n", sr.Param, sr.Best, sr.BestQPS)
+		default:
+			fmt.Printf("Result: %s=%g (%.2f qps)\n", sr.Param, sr.Best, sr.BestQPS)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+// PrintComparison prints compared results side by side, each after the
+// baseline with its change in percent, then any regressions.
+func PrintComparison(format config.OutputFormat, c metrics.Comparison) error {
+	switch format {
+	case config.OutputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(c)
+	case config.OutputText, "":
+		width := 30
+		for i, f := range c.Files {
+			runs := ""
+			if c.Runs[i] > 1 {
+				runs = fmt.Sprintf(" (mean of %d runs)", c.Runs[i])
+			}
+			fmt.Printf("[%d] %s: %s%s\n", i+1, f, c.Names[i], runs)
+		}
+		if len(c.Mismatches) > 0 {
+			fmt.Println("Differences from the baseline:")
+			for _, m := range c.Mismatches {
+				fmt.Printf("  %s\n", m)
+			}
+		}
+		fmt.Printf("%-8s", "metric")
+		for i := range c.Files {
+			fmt.Printf(" %*s", width, fmt.Sprintf("[%d]", i+1))
+		}
+		fmt.Println()
+		for _, row := range c.Rows {
+			unit := "ms"
+			switch row.Metric {
+			case "qps", "errors":
+				unit = ""
+			}
+			prec := 3
+			switch row.Metric {
+			case "qps":
+				prec = 2
+			case "errors":
+				prec = 0
+			}
+			fmt.Printf("%-8s", row.Metric)
+			for i, v := range row.Values {
+				cell := fmt.Sprintf("%.*f%s", prec, v, unit)
+				if i > 0 {
+					pct := "n/a"
+					if p := row.DeltaPct[i]; p != nil {
+						pct = fmt.Sprintf("%+.1f%%", *p)
+					}
+					cell += fmt.Sprintf(" (%+.*f, %s)", prec, row.Delta[i], pct)
+				}
+				fmt.Printf(" %*s", width, cell)
+			}
+			fmt.Println()
+		}
+		header := false
+		for _, row := range c.Rows {
+			for i, w := range row.Welch {
+				if w == nil {
+					continue
+				}
+				if !header {
+					fmt.Printf("Welch's t-test against [1] (alpha %g):\n", c.Alpha)
+					header = true
+				}
+				verdict := "not significant"
+				if w.P < c.Alpha {
+					verdict = "significant"
+				}
+				fmt.Printf("  %-8s [%d] t=%.3f df=%.1f p=%.4f %s\n", row.Metric, i+1, w.T, w.DF, w.P, verdict)
+			}
+		}
+		if len(c.Regressions) > 0 {
+			fmt.Println("Regressions:")
+			for _, r := range c.Regressions {
+				fmt.Printf("  %s\n", r)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+// PrintRepeated prints one line per repeat and the spread of each metric;
+// JSON output also carries every repeat's full summary.
+func PrintRepeated(format config.OutputFormat, r metrics.Repeated) error {
+	switch format {
+	case config.OutputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case config.OutputText, "":
+		fmt.Printf("Name: %s\n", r.Name)
+		fmt.Printf("Repeats: %d (cooldown %s)\n", r.Repeats, r.Cooldown)
+		fmt.Printf("%6s %12s %10s %10s %10s %10s %10s %8s\n", "repeat", "qps", "avg(ms)", "p50(ms)", "p95(ms)", "p99(ms)", "p999(ms)", "errors")
+		for i, s := range r.Runs {
+			fmt.Printf("%6d %12.2f %10.3f %10.3f %10.3f %10.3f %10.3f %8d\n", i+1, s.QPS, s.AvgMs, s.P50Ms, s.P95Ms, s.P99Ms, s.P999Ms, s.Errors)
+		}
+		fmt.Printf("%-8s %12s %12s %27s\n", "metric", "mean", "stddev", "95% CI")
+		for _, row := range []struct {
+			name string
+			st   metrics.Stat
+		}{
+			{"qps", r.QPS}, {"avg(ms)", r.AvgMs}, {"p50(ms)", r.P50Ms}, {"p95(ms)", r.P95Ms}, {"p99(ms)", r.P99Ms}, {"p999(ms)", r.P999Ms},
+		} {
+			fmt.Printf("%-8s %12.3f %12.3f %12.3f .. %12.3f\n", row.name, row.st.Mean, row.st.StdDev, row.st.CI95Low, row.st.CI95High)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+func percent(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+func formatSettings(settings map[string]string) string {
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%s", k, settings[k])
+	}
+	return b.String()
+}
+
+func formatCounts(counts map[string]int64) string {
+	if len(counts) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%d", k, counts[k]))
+	}
+	return " (" + strings.Join(parts, " ") + ")"
+}
+
+// formatTables lists table op counts in numeric order (t2 before t10) and
+// the ratio of the busiest to the least busy table.
+func formatTables(counts map[string]int64) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) < len(names[j])
+		}
+		return names[i] < names[j]
+	})
+	var b strings.Builder
+	lo, hi := counts[names[0]], counts[names[0]]
+	for _, name := range names {
+		n := counts[name]
+		fmt.Fprintf(&b, " %s=%d", name, n)
+		lo, hi = min(lo, n), max(hi, n)
+	}
+	if lo > 0 {
+		fmt.Fprintf(&b, " (max/min=%.2f)", float64(hi)/float64(lo))
+	}
+	return b.String()
+}
+
+func formatParams(params map[string]float64) string {
+	if len(params) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%g", k, params[k]))
+	}
+	return " (" + strings.Join(parts, " ") + ")"
+}
+
+// PrintInterval prints one periodic report while a run is in progress.
+// With JSON output the line goes to stderr so stdout stays a single object;
+// the intervals are included in the final JSON instead.
+func PrintInterval(format config.OutputFormat, iv metrics.Interval) {
+	w := os.Stdout
+	if format == config.OutputJSON {
+		w = os.Stderr
+	}
+	elapsed := time.Duration(iv.ElapsedSec * float64(time.Second)).Round(time.Millisecond)
+	fmt.Fprintf(w, "[ %s ] qps: %.2f err/s: %.2f lat (ms,p50/p95/p99): %.3f/%.3f/%.3f\n",
+		elapsed, iv.QPS, iv.ErrorsPerSec, iv.P50Ms, iv.P95Ms, iv.P99Ms)
+}
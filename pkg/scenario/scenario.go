@@ -0,0 +1,211 @@
+// Package scenario runs benchmark definitions kept in YAML files: a base
+// configuration and a sequence of phases, each a prepare or a workload run.
+package scenario
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/db"
+	"tidb-benchmarks/pkg/metrics"
+	"tidb-benchmarks/pkg/workload"
+)
+
+// KindPrepare is the phase kind that creates and loads the table; every
+// other kind names a run workload.
+const KindPrepare = "prepare"
+
+// Scenario is a parsed scenario file. Settings are given by their command
+// line flag names without the dashes, e.g.
+//
+//	name: nightly
+//	base:
+//	  db: tidb
+//	  table-size: 1000000
+//	phases:
+//	  - kind: prepare
+//	    threads: 32
+//	  - name: warm reads
+//	    kind: read-only
+//	    time: 5m
+//	    threads: 64
+//	    key-dist: zipfian
+//
+// Each phase starts from the configuration given on the command line,
+// then applies base, then its own settings.
+type Scenario struct {
+	Name   string         `yaml:"name"`
+	Base   map[string]any `yaml:"base"`
+	Phases []Phase        `yaml:"phases"`
+}
+
+// Phase is one step of a scenario.
+type Phase struct {
+	Name string `yaml:"name"`
+	Kind string `yaml:"kind"`
+	// Settings holds the remaining keys, such as time, threads, rate and
+	// key-dist.
+	Settings map[string]any `yaml:",inline"`
+}
+
+// Load reads and checks a scenario file.
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var sc Scenario
+	if err := dec.Decode(&sc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(sc.Phases) == 0 {
+		return nil, fmt.Errorf("%s: no phases", path)
+	}
+	for i := range sc.Phases {
+		p := &sc.Phases[i]
+		if p.Kind == "" {
+			return nil, fmt.Errorf("%s: phase %d has no kind", path, i+1)
+		}
+		if p.Name == "" {
+			p.Name = p.Kind
+		}
+	}
+	if sc.Name == "" {
+		sc.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return &sc, nil
+}
+
+// Config returns the configuration of phase i: base, then the scenario's
+// base settings, then the phase's. YCSB phases get their own key
+// distribution unless key-dist is set in the file. It fails when the phase
+// kind is unknown or its settings do not make a valid mix.
+func (sc *Scenario) Config(base config.Config, i int) (config.Config, error) {
+	p := sc.Phases[i]
+	cfg := base
+	fs := pflag.NewFlagSet(sc.Name, pflag.ContinueOnError)
+	config.BindCommonFlags(fs, &cfg)
+	workload.BindPrepareFlags(fs, &cfg)
+	workload.BindRunFlags(fs, &cfg)
+	workload.BindMixedFlags(fs, &cfg)
+	workload.BindScanFlags(fs, &cfg)
+	workload.BindBankFlags(fs, &cfg)
+	workload.BindCASFlags(fs, &cfg)
+	if err := apply(fs, sc.Base, p); err != nil {
+		return cfg, err
+	}
+
+	if p.Kind != KindPrepare {
+		kind := workload.Kind(p.Kind)
+		if err := workload.Check(kind, cfg); err != nil {
+			return cfg, fmt.Errorf("phase %q: %w", p.Name, err)
+		}
+		if d := kind.DefaultKeyDist(); d != "" && !fs.Changed("key-dist") {
+			cfg.KeyDist = d
+		}
+		if cfg.Time <= 0 {
+			cfg.Time = 30 * time.Second
+		}
+	}
+	return cfg, nil
+}
+
+// apply sets the flags the base and phase p name, each once: pflag appends
+// to a slice flag such as retry-on on every Set, so a phase setting must
+// replace the base one before it reaches fs.
+func apply(fs *pflag.FlagSet, base map[string]any, p Phase) error {
+	settings := maps.Clone(base)
+	if settings == nil {
+		settings = make(map[string]any)
+	}
+	maps.Copy(settings, p.Settings)
+	for name, v := range settings {
+		where := "base"
+		if _, ok := p.Settings[name]; ok {
+			where = fmt.Sprintf("phase %q", p.Name)
+		}
+		if err := set(fs, name, v); err != nil {
+			return fmt.Errorf("%s: %w", where, err)
+		}
+	}
+	return nil
+}
+
+// set sets the flag name to a YAML value; lists become comma-separated.
+func set(fs *pflag.FlagSet, name string, v any) error {
+	var value string
+	switch v := v.(type) {
+	case []any:
+		parts := make([]string, len(v))
+		for i, p := range v {
+			parts[i] = fmt.Sprint(p)
+		}
+		value = strings.Join(parts, ",")
+	default:
+		value = fmt.Sprint(v)
+	}
+	if fs.Lookup(name) == nil {
+		return fmt.Errorf("unknown setting %q", name)
+	}
+	if err := fs.Set(name, value); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// Run runs every phase in order, each with its own connection and
+// --timeout. Every phase's configuration is built first, so a typo in the
+// last phase fails before the first one runs. It stops at the first
+// failed phase and returns the phases that ran, the failed one with what
+// it measured and its error, along with the error.
+func Run(ctx context.Context, base config.Config, sc *Scenario) (metrics.Scenario, error) {
+	cfgs := make([]config.Config, len(sc.Phases))
+	for i := range sc.Phases {
+		cfg, err := sc.Config(base, i)
+		if err != nil {
+			return metrics.Scenario{}, err
+		}
+		cfgs[i] = cfg
+	}
+
+	res := metrics.Scenario{Name: sc.Name}
+	for i, p := range sc.Phases {
+		s, err := runPhase(ctx, cfgs[i], p.Kind)
+		phase := metrics.Phase{Name: p.Name, Kind: p.Kind, Summary: s}
+		if err != nil {
+			phase.Error = err.Error()
+			res.Phases = append(res.Phases, phase)
+			return res, fmt.Errorf("phase %q: %w", p.Name, err)
+		}
+		res.Phases = append(res.Phases, phase)
+	}
+	return res, nil
+}
+
+func runPhase(ctx context.Context, cfg config.Config, kind string) (metrics.Summary, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	client, err := db.Open(ctx, cfg)
+	if err != nil {
+		return metrics.Summary{}, err
+	}
+	defer client.Close()
+
+	if kind == KindPrepare {
+		return workload.Prepare(ctx, client, cfg)
+	}
+	return workload.Run(ctx, client, cfg, workload.Kind(kind))
+}
@@ -0,0 +1,116 @@
+package scenario
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tidb-benchmarks/pkg/config"
+)
+
+func load(t *testing.T, yaml string) (*Scenario, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "nightly.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+func TestConfig(t *testing.T) {
+	sc, err := load(t, `
+base:
+  table-size: 5000
+  threads: 8
+  retry-on: [unavailable]
+phases:
+  - kind: prepare
+  - name: reads
+    kind: read-only
+    threads: 32
+    key-dist: zipfian
+  - kind: ycsb-a
+  - kind: ycsb-b
+    key-dist: uniform
+  - kind: mixed
+    read-ratio: 0.9
+    retry-on: [conflict, timeout]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.Name != "nightly" {
+		t.Errorf("name = %q, want the file name", sc.Name)
+	}
+	tests := []struct {
+		name    string
+		threads int
+		keyDist config.KeyDist
+		check   func(cfg config.Config) bool
+	}{
+		{"prepare", 8, config.KeyDistUniform, func(cfg config.Config) bool {
+			return strings.Join(cfg.RetryOn, ",") == "unavailable"
+		}},
+		{"reads", 32, config.KeyDistZipfian, nil},
+		{"ycsb-a", 8, config.KeyDistScrambled, nil},
+		{"ycsb-b", 8, config.KeyDistUniform, nil},
+		{"mixed", 8, config.KeyDistUniform, func(cfg config.Config) bool {
+			return cfg.ReadRatio == 0.9 && strings.Join(cfg.RetryOn, ",") == "conflict,timeout"
+		}},
+	}
+	for i, tt := range tests {
+		if sc.Phases[i].Name != tt.name {
+			t.Errorf("phase %d is named %q, want %q", i, sc.Phases[i].Name, tt.name)
+		}
+		cfg, err := sc.Config(config.Default(), i)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if cfg.TableSize != 5000 || cfg.Threads != tt.threads || cfg.KeyDist != tt.keyDist {
+			t.Errorf("%s: table-size %d, threads %d, key-dist %s, want 5000, %d, %s",
+				tt.name, cfg.TableSize, cfg.Threads, cfg.KeyDist, tt.threads, tt.keyDist)
+		}
+		if tt.check != nil && !tt.check(cfg) {
+			t.Errorf("%s: settings not applied: %+v", tt.name, cfg)
+		}
+	}
+}
+
+func TestConfigRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		phase string
+	}{
+		{"unknown kind", "kind: nope"},
+		{"unknown setting", "kind: read-only\n    colour: blue"},
+		{"bad value", "kind: read-only\n    threads: many"},
+		{"ratios past 1", "kind: mixed\n    scan-ratio: 0.7\n    insert-ratio: 0.5"},
+	}
+	for _, tt := range tests {
+		sc, err := load(t, "phases:\n  - kind: prepare\n  - "+tt.phase+"\n")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if _, err := sc.Config(config.Default(), 1); err == nil {
+			t.Errorf("%s: Config succeeded, want an error", tt.name)
+		}
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{"no phases", "name: empty\n"},
+		{"phase without kind", "phases:\n  - threads: 4\n"},
+		{"unknown top-level key", "phases:\n  - kind: prepare\nextra: 1\n"},
+	}
+	for _, tt := range tests {
+		if _, err := load(t, tt.yaml); err == nil {
+			t.Errorf("%s: Load succeeded, want an error", tt.name)
+		}
+	}
+}
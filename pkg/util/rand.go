@@ -29,3 +29,8 @@ func (r *SplitMix64) Int63n(n int64) int64 {
 	}
 	return int64(r.Next() % uint64(n))
 }
+
+// Float64 returns a uniformly distributed value in [0, 1).
+func (r *SplitMix64) Float64() float64 {
+	return float64(r.Next()>>11) / (1 << 53)
+}
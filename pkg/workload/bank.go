@@ -0,0 +1,135 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/db"
+	"tidb-benchmarks/pkg/metrics"
+)
+
+// bankMaxTransfer bounds the amount of one transfer.
+const bankMaxTransfer = 10
+
+// bankLoadBatch is the number of rows written per InsertBatch when
+// loading a bank or cas table.
+const bankLoadBatch = 1000
+
+// bankConfig points a bank run at its own table, <table>_bank, holding
+// --accounts rows so the key distribution picks accounts.
+func bankConfig(cfg config.Config) (config.Config, error) {
+	if cfg.BankAccounts < 2 {
+		return cfg, fmt.Errorf("accounts must be >= 2")
+	}
+	if cfg.BankBalance < 0 {
+		return cfg, fmt.Errorf("initial-balance must be >= 0")
+	}
+	cfg.Table += "_bank"
+	cfg.Tables = 0
+	cfg.TableSize = cfg.BankAccounts
+	return cfg, nil
+}
+
+// loadBank empties the bank table and opens every account with the
+// initial balance in k.
+func loadBank(ctx context.Context, client db.Client, cfg config.Config, payload []byte) error {
+	return loadConstant(ctx, client, cfg, cfg.BankBalance, payload)
+}
+
+// loadConstant empties cfg.Table and writes ids 1..TableSize, all with k.
+func loadConstant(ctx context.Context, client db.Client, cfg config.Config, k int64, payload []byte) error {
+	if err := client.Truncate(ctx, cfg); err != nil {
+		return err
+	}
+	ks := make([]int64, bankLoadBatch)
+	for i := range ks {
+		ks[i] = k
+	}
+	for id := int64(1); id <= cfg.TableSize; id += bankLoadBatch {
+		n := min(int64(bankLoadBatch), cfg.TableSize-id+1)
+		if err := client.InsertBatch(ctx, cfg, id, ks[:n], payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bankChecker verifies that the total balance stays at its opening value.
+type bankChecker struct {
+	client db.Client
+	cfg    config.Config
+	start  time.Time
+
+	mu  sync.Mutex
+	inv metrics.Invariant
+}
+
+func newBankChecker(client db.Client, cfg config.Config, start time.Time) *bankChecker {
+	return &bankChecker{
+		client: client,
+		cfg:    cfg,
+		start:  start,
+		inv: metrics.Invariant{
+			Expected: cfg.BankAccounts * cfg.BankBalance,
+			Snapshot: db.SnapshotTotals(cfg),
+		},
+	}
+}
+
+// check reads the total once and records a violation if it is off.
+func (b *bankChecker) check(ctx context.Context, now time.Time, final bool) error {
+	sum, rows, err := b.client.Total(ctx, b.cfg)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.inv.Checks++
+	if sum != b.inv.Expected || rows != b.cfg.BankAccounts {
+		b.inv.Violations = append(b.inv.Violations, metrics.Violation{
+			ElapsedSec: now.Sub(b.start).Seconds(),
+			Got:        sum,
+			Rows:       rows,
+			Final:      final,
+		})
+	}
+	return nil
+}
+
+// run checks every --check-interval until the returned stop func is
+// called; stop waits for an ongoing check. Failed checks are skipped, the
+// final one decides.
+func (b *bankChecker) run(ctx context.Context) (stop func()) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		if b.cfg.BankCheckInterval <= 0 {
+			return
+		}
+		ticker := time.NewTicker(b.cfg.BankCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				_ = b.check(ctx, now, false)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+func (b *bankChecker) result() *metrics.Invariant {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	inv := b.inv
+	return &inv
+}
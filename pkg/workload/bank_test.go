@@ -0,0 +1,77 @@
+package workload
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/db"
+)
+
+// totals returns one canned Total per call.
+type totals struct {
+	db.Client
+	sums, rows []int64
+	errs       []error
+	calls      int
+}
+
+func (f *totals) Total(context.Context, config.Config) (int64, int64, error) {
+	i := f.calls
+	f.calls++
+	return f.sums[i], f.rows[i], f.errs[i]
+}
+
+func TestBankChecker(t *testing.T) {
+	type reading struct {
+		sum, rows int64
+		err       error
+	}
+	ok := reading{sum: 10 * 100, rows: 10}
+	tests := []struct {
+		name       string
+		readings   []reading // the last one is the final check
+		checks     int
+		violations []int // indexes of the readings that violate
+	}{
+		{"steady", []reading{ok, ok, ok}, 3, nil},
+		{"money created", []reading{ok, {sum: 1001, rows: 10}, ok}, 3, []int{1}},
+		{"money lost at the end", []reading{ok, ok, {sum: 990, rows: 10}}, 3, []int{2}},
+		{"account lost", []reading{{sum: 1000, rows: 9}, ok}, 2, []int{0}},
+		{"failed check skipped", []reading{{err: errors.New("timeout")}, ok}, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &totals{}
+			for _, r := range tt.readings {
+				f.sums, f.rows, f.errs = append(f.sums, r.sum), append(f.rows, r.rows), append(f.errs, r.err)
+			}
+			cfg := config.Default()
+			cfg.BankAccounts, cfg.BankBalance = 10, 100
+			start := time.Unix(0, 0)
+			b := newBankChecker(f, cfg, start)
+			for i, r := range tt.readings {
+				final := i == len(tt.readings)-1
+				if err := b.check(context.Background(), start.Add(time.Duration(i+1)*time.Second), final); (err != nil) != (r.err != nil) {
+					t.Fatalf("check %d: error %v, want %v", i, err, r.err)
+				}
+			}
+
+			inv := b.result()
+			if inv.Expected != 1000 || inv.Checks != tt.checks {
+				t.Errorf("expected %d over %d checks, want 1000 over %d", inv.Expected, inv.Checks, tt.checks)
+			}
+			if len(inv.Violations) != len(tt.violations) {
+				t.Fatalf("violations %+v, want at readings %v", inv.Violations, tt.violations)
+			}
+			for j, i := range tt.violations {
+				v, r := inv.Violations[j], tt.readings[i]
+				if v.Got != r.sum || v.Rows != r.rows || v.ElapsedSec != float64(i+1) || v.Final != (i == len(tt.readings)-1) {
+					t.Errorf("violation %+v, want reading %d: %+v", v, i, r)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/db/dberr"
+)
+
+// casConfig points a cas run at its own table, <table>_cas, holding
+// --cas-keys rows whose k is a version starting at 0.
+func casConfig(cfg config.Config) (config.Config, error) {
+	if cfg.CASKeys < 1 {
+		return cfg, fmt.Errorf("cas-keys must be >= 1")
+	}
+	if cfg.CASRetries < 0 {
+		return cfg, fmt.Errorf("cas-retries must be >= 0")
+	}
+	cfg.Table += "_cas"
+	cfg.Tables = 0
+	cfg.TableSize = cfg.CASKeys
+	return cfg, nil
+}
+
+// cas reads the version of id and bumps it by one with compare-and-set.
+// An attempt that loses to another writer retries from the version it
+// found; one that hits an engine write conflict reads the version again.
+// Losing every attempt is an outcome, not an error.
+func (r *runner) cas(ctx context.Context, t *table, id int64) (attempts int, applied bool, err error) {
+	cur, err := r.client.ReadK(ctx, t.cfg, id)
+	if err != nil {
+		return 1, false, err
+	}
+	for attempts = 1; ; attempts++ {
+		var ok bool
+		ok, cur, err = r.client.CompareAndSet(ctx, t.cfg, id, cur, cur+1, r.payload)
+		if err != nil && r.client.ClassifyError(err) != dberr.ClassConflict {
+			return attempts, false, err
+		}
+		if ok {
+			return attempts, true, nil
+		}
+		if attempts > r.cfg.CASRetries {
+			return attempts, false, nil
+		}
+		if err != nil {
+			if cur, err = r.client.ReadK(ctx, t.cfg, id); err != nil {
+				return attempts, false, err
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package workload
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/db"
+	"tidb-benchmarks/pkg/db/dberr"
+)
+
+// contended answers compare-and-set attempts in turn: "ok" applies,
+// "lost" finds another version, anything else fails with an error of
+// that class.
+type contended struct {
+	db.Client
+	answers []string
+	version int64
+	reads   int
+}
+
+func (f *contended) ReadK(context.Context, config.Config, int64) (int64, error) {
+	f.reads++
+	return f.version, nil
+}
+
+func (f *contended) CompareAndSet(_ context.Context, _ config.Config, _, old, next int64, _ []byte) (bool, int64, error) {
+	a := f.answers[0]
+	f.answers = f.answers[1:]
+	switch a {
+	case "ok":
+		f.version = next
+		return true, next, nil
+	case "lost":
+		f.version = old + 5
+		return false, f.version, nil
+	default:
+		return false, 0, errors.New(a)
+	}
+}
+
+func (f *contended) ClassifyError(err error) dberr.Class { return dberr.Class(err.Error()) }
+
+func TestCAS(t *testing.T) {
+	tests := []struct {
+		name     string
+		answers  []string
+		attempts int
+		applied  bool
+		wantErr  bool
+		reads    int
+	}{
+		{"applied", []string{"ok"}, 1, true, false, 1},
+		{"lost, then applied", []string{"lost", "lost", "ok"}, 3, true, false, 1},
+		{"conflict reads again", []string{"conflict", "ok"}, 2, true, false, 2},
+		{"every attempt lost", []string{"lost", "lost", "lost", "lost"}, 4, false, false, 1},
+		{"timeout is not retried", []string{"timeout"}, 1, false, true, 1},
+		{"timeout after a lost attempt", []string{"lost", "timeout"}, 2, false, true, 1},
+	}
+	for _, tt := range tests {
+		f := &contended{answers: tt.answers}
+		cfg := config.Default()
+		cfg.CASRetries = 3
+		r := &runner{client: f, cfg: cfg}
+		attempts, applied, err := r.cas(context.Background(), &table{cfg: cfg}, 1)
+		if attempts != tt.attempts || applied != tt.applied || (err != nil) != tt.wantErr {
+			t.Errorf("%s: %d attempts, applied %v, error %v; want %d, %v, error %v",
+				tt.name, attempts, applied, err, tt.attempts, tt.applied, tt.wantErr)
+		}
+		if f.reads != tt.reads {
+			t.Errorf("%s: %d reads, want %d", tt.name, f.reads, tt.reads)
+		}
+		if len(f.answers) != 0 {
+			t.Errorf("%s: %d attempts left unmade", tt.name, len(f.answers))
+		}
+	}
+}
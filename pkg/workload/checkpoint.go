@@ -0,0 +1,162 @@
+package workload
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// checkpointHeader is the first line of a checkpoint file. A resumed
+// prepare must use the same table, id range and chunk size.
+type checkpointHeader struct {
+	Table     string `json:"table"`
+	Start     int64  `json:"start"`
+	End       int64  `json:"end"`
+	ChunkSize int64  `json:"chunk_size"`
+}
+
+type chunkState string
+
+const (
+	chunkStarted chunkState = "started"
+	chunkDone    chunkState = "done"
+)
+
+// checkpointEntry records a state change of one chunk. Chunk i covers ids
+// Start+i*ChunkSize .. Start+(i+1)*ChunkSize-1, capped at End.
+type checkpointEntry struct {
+	Chunk int64      `json:"chunk"`
+	State chunkState `json:"state"`
+}
+
+// checkpoint is an append-only log of chunk progress for prepare. Without
+// a file it only tracks the chunks in memory.
+type checkpoint struct {
+	mu sync.Mutex
+	f  *os.File
+
+	header checkpointHeader
+	states map[int64]chunkState
+}
+
+// openCheckpoint starts a new checkpoint at path or, with resume, loads the
+// existing one. A zero header.ChunkSize on resume adopts the stored size.
+// An empty path keeps no file.
+func openCheckpoint(path string, header checkpointHeader, resume bool) (*checkpoint, error) {
+	c := &checkpoint{header: header, states: make(map[int64]chunkState)}
+	if path == "" {
+		return c, nil
+	}
+	if !resume {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		c.f = f
+		if err := c.append(header); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		return c, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("resume: %w", err)
+	}
+	c.f = f
+	if err := c.load(header); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *checkpoint) load(want checkpointHeader) error {
+	sc := bufio.NewScanner(c.f)
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return err
+		}
+		return errors.New("resume: checkpoint file is empty")
+	}
+	var got checkpointHeader
+	if err := json.Unmarshal(sc.Bytes(), &got); err != nil {
+		return fmt.Errorf("resume: bad checkpoint header: %w", err)
+	}
+	if want.ChunkSize == 0 {
+		want.ChunkSize = got.ChunkSize
+	}
+	if got != want {
+		return fmt.Errorf("resume: checkpoint is for %+v, this prepare is %+v", got, want)
+	}
+	c.header = got
+
+	for sc.Scan() {
+		var e checkpointEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			// A torn final line from a crash; the chunk is redone.
+			continue
+		}
+		if c.states[e.Chunk] != chunkDone {
+			c.states[e.Chunk] = e.State
+		}
+	}
+	return sc.Err()
+}
+
+func (c *checkpoint) append(v any) error {
+	if c.f == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if _, err := c.f.Write(b); err != nil {
+		return err
+	}
+	return c.f.Sync()
+}
+
+// Mark records a chunk state change.
+func (c *checkpoint) Mark(chunk int64, state chunkState) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.states[chunk] = state
+	return c.append(checkpointEntry{Chunk: chunk, State: state})
+}
+
+// State returns what the checkpoint knows about chunk, or "" if nothing.
+func (c *checkpoint) State(chunk int64) chunkState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.states[chunk]
+}
+
+// Chunks returns the number of chunks in the id range.
+func (c *checkpoint) Chunks() int64 {
+	n := c.header.End - c.header.Start + 1
+	return (n + c.header.ChunkSize - 1) / c.header.ChunkSize
+}
+
+// Bounds returns the first and last id of chunk.
+func (c *checkpoint) Bounds(chunk int64) (int64, int64) {
+	lo := c.header.Start + chunk*c.header.ChunkSize
+	hi := lo + c.header.ChunkSize - 1
+	if hi > c.header.End {
+		hi = c.header.End
+	}
+	return lo, hi
+}
+
+func (c *checkpoint) Close() error {
+	if c.f == nil {
+		return nil
+	}
+	return c.f.Close()
+}
@@ -0,0 +1,190 @@
+package workload
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"tidb-benchmarks/pkg/config"
+)
+
+func TestParseIDRange(t *testing.T) {
+	tests := []struct {
+		spec    string
+		lo, hi  int64
+		wantErr bool
+	}{
+		{"", 1, 1000, false},
+		{"1:1000", 1, 1000, false},
+		{"501:1000", 501, 1000, false},
+		{" 10 : 20 ", 10, 20, false},
+		{"7:7", 7, 7, false},
+		{"0:10", 0, 0, true},
+		{"1:1001", 0, 0, true},
+		{"20:10", 0, 0, true},
+		{"10", 0, 0, true},
+		{"a:10", 0, 0, true},
+		{"1:b", 0, 0, true},
+	}
+	for _, tt := range tests {
+		lo, hi, err := parseIDRange(tt.spec, 1000)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseIDRange(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if lo != tt.lo || hi != tt.hi {
+			t.Errorf("parseIDRange(%q) = %d, %d, want %d, %d", tt.spec, lo, hi, tt.lo, tt.hi)
+		}
+	}
+}
+
+func TestCheckpointPath(t *testing.T) {
+	tests := []struct {
+		name       string
+		checkpoint string
+		idRange    string
+		resume     bool
+		multi      bool
+		chunks     int64
+		want       string
+	}{
+		{"plain load", "", "", false, false, 8, "sbtest.checkpoint"},
+		{"plain load, one chunk", "", "", false, false, 1, ""},
+		{"resume", "", "", true, false, 0, "sbtest.checkpoint"},
+		{"id range", "", "1:500", false, false, 8, "sbtest.1-500.checkpoint"},
+		{"id range, one chunk", "", "1:500", false, false, 1, "sbtest.1-500.checkpoint"},
+		{"explicit", "load.ckpt", "", false, false, 1, "load.ckpt"},
+		{"explicit, several tables", "load.ckpt", "", false, true, 8, "load.ckpt.sbtest"},
+		{"explicit wins over id range", "load.ckpt", "1:500", true, false, 8, "load.ckpt"},
+	}
+	for _, tt := range tests {
+		cfg := config.Default()
+		cfg.Table, cfg.Checkpoint, cfg.IDRange, cfg.Resume = "sbtest", tt.checkpoint, tt.idRange, tt.resume
+		if got := checkpointPath(cfg, tt.multi, 1, 500, tt.chunks); got != tt.want {
+			t.Errorf("%s: checkpointPath() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckpointBounds(t *testing.T) {
+	tests := []struct {
+		start, end, size int64
+		chunks           int64
+		bounds           [][2]int64
+	}{
+		{1, 10, 5, 2, [][2]int64{{1, 5}, {6, 10}}},
+		{1, 11, 5, 3, [][2]int64{{1, 5}, {6, 10}, {11, 11}}},
+		{501, 1000, 200, 3, [][2]int64{{501, 700}, {701, 900}, {901, 1000}}},
+		{7, 7, 100, 1, [][2]int64{{7, 7}}},
+	}
+	for _, tt := range tests {
+		c, err := openCheckpoint("", checkpointHeader{Table: "t", Start: tt.start, End: tt.end, ChunkSize: tt.size}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Chunks(); got != tt.chunks {
+			t.Errorf("[%d, %d] by %d: Chunks() = %d, want %d", tt.start, tt.end, tt.size, got, tt.chunks)
+			continue
+		}
+		for i, want := range tt.bounds {
+			if lo, hi := c.Bounds(int64(i)); lo != want[0] || hi != want[1] {
+				t.Errorf("[%d, %d] by %d: Bounds(%d) = %d, %d, want %d, %d", tt.start, tt.end, tt.size, i, lo, hi, want[0], want[1])
+			}
+		}
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+	header := checkpointHeader{Table: "t", Start: 1, End: 100, ChunkSize: 10}
+	tests := []struct {
+		name    string
+		resume  checkpointHeader
+		torn    string
+		want    map[int64]chunkState
+		wantErr bool
+	}{
+		{
+			name:   "same header",
+			resume: header,
+			want:   map[int64]chunkState{0: chunkDone, 1: chunkDone, 2: chunkStarted, 3: ""},
+		},
+		{
+			name:   "adopts the stored chunk size",
+			resume: checkpointHeader{Table: "t", Start: 1, End: 100},
+			want:   map[int64]chunkState{0: chunkDone, 1: chunkDone, 2: chunkStarted},
+		},
+		{
+			name:   "torn last line",
+			resume: header,
+			torn:   `{"chunk":3,"sta`,
+			want:   map[int64]chunkState{2: chunkStarted, 3: ""},
+		},
+		{
+			name:    "other range",
+			resume:  checkpointHeader{Table: "t", Start: 1, End: 200, ChunkSize: 10},
+			wantErr: true,
+		},
+		{
+			name:    "other table",
+			resume:  checkpointHeader{Table: "u", Start: 1, End: 100, ChunkSize: 10},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "t.checkpoint")
+			c, err := openCheckpoint(path, header, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, m := range []struct {
+				chunk int64
+				state chunkState
+			}{{0, chunkStarted}, {1, chunkStarted}, {0, chunkDone}, {2, chunkStarted}, {1, chunkDone}} {
+				if err := c.Mark(m.chunk, m.state); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := c.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if tt.torn != "" {
+				f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = f.WriteString(tt.torn)
+				if cerr := f.Close(); err == nil {
+					err = cerr
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c, err = openCheckpoint(path, tt.resume, true)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resume error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer c.Close()
+			if c.header != header {
+				t.Errorf("header = %+v, want %+v", c.header, header)
+			}
+			for chunk, want := range tt.want {
+				if got := c.State(chunk); got != want {
+					t.Errorf("State(%d) = %q, want %q", chunk, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckpointResumeMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.checkpoint")
+	if _, err := openCheckpoint(path, checkpointHeader{Table: "t", Start: 1, End: 10, ChunkSize: 5}, true); err == nil {
+		t.Fatal("resume without a checkpoint file succeeded")
+	}
+}
@@ -0,0 +1,61 @@
+package workload
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"tidb-benchmarks/pkg/config"
+)
+
+// minOpsForErrorRate is how many operations must finish before
+// --max-error-rate is enforced, so a single early failure does not abort
+// the run.
+const minOpsForErrorRate = 100
+
+// errorBudget decides whether workers keep going after a failed operation.
+// With neither threshold set the first error ends the run.
+type errorBudget struct {
+	maxErrors int64
+	maxRate   float64
+
+	ops    int64
+	errors int64
+}
+
+func newErrorBudget(cfg config.Config) *errorBudget {
+	return &errorBudget{maxErrors: cfg.MaxErrors, maxRate: cfg.MaxErrorRate}
+}
+
+func (b *errorBudget) enabled() bool {
+	return b.maxErrors > 0 || b.maxRate > 0
+}
+
+// observe accounts for one finished operation and returns a non-nil error
+// when the run must stop. Operations before measuring starts are not
+// counted, so the budget matches the errors the summary reports; a failed
+// one only ends the run when there is no budget.
+func (b *errorBudget) observe(err error, measuring bool) error {
+	if !b.enabled() {
+		return err
+	}
+	if !measuring {
+		return nil
+	}
+	var ops int64
+	if b.maxRate > 0 {
+		ops = atomic.AddInt64(&b.ops, 1)
+	}
+	if err == nil {
+		return nil
+	}
+	errors := atomic.AddInt64(&b.errors, 1)
+	if b.maxErrors > 0 && errors > b.maxErrors {
+		return fmt.Errorf("more than %d errors, last: %w", b.maxErrors, err)
+	}
+	if b.maxRate > 0 && ops >= minOpsForErrorRate {
+		if rate := float64(errors) / float64(ops); rate > b.maxRate {
+			return fmt.Errorf("error rate %.4f exceeds %.4f, last: %w", rate, b.maxRate, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package workload
+
+import (
+	"errors"
+	"testing"
+
+	"tidb-benchmarks/pkg/config"
+)
+
+func TestErrorBudget(t *testing.T) {
+	errFailed := errors.New("failed")
+	tests := []struct {
+		name      string
+		maxErrors int64
+		maxRate   float64
+		warmup    int // failed operations before measuring starts
+		ok, fail  int // operations while measuring: ok first, then failed
+		stopAfter int // failed operation while measuring that stops the run, 0 = none, -1 = one in warmup
+	}{
+		{name: "no budget", fail: 3, stopAfter: 1},
+		{name: "no budget, error in warmup", warmup: 1, stopAfter: -1},
+		{name: "count within budget", maxErrors: 3, ok: 10, fail: 3},
+		{name: "count past budget", maxErrors: 3, ok: 10, fail: 5, stopAfter: 4},
+		{name: "warmup errors not counted", maxErrors: 3, warmup: 10, ok: 10, fail: 3},
+		{name: "rate within budget", maxRate: 0.1, ok: 180, fail: 20},
+		{name: "rate past budget", maxRate: 0.1, ok: 180, fail: 30, stopAfter: 21},
+		{name: "rate not enforced before enough ops", maxRate: 0.1, ok: 10, fail: 95, stopAfter: minOpsForErrorRate - 10},
+		{name: "warmup errors do not raise the rate", maxRate: 0.1, warmup: 100, ok: 180, fail: 20},
+		{name: "both, count first", maxErrors: 5, maxRate: 0.5, ok: 100, fail: 10, stopAfter: 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.MaxErrors, cfg.MaxErrorRate = tt.maxErrors, tt.maxRate
+			b := newErrorBudget(cfg)
+			for i := 0; i < tt.warmup; i++ {
+				if err := b.observe(errFailed, false); err != nil {
+					if tt.stopAfter != -1 {
+						t.Fatalf("warmup error %d stopped the run: %v", i+1, err)
+					}
+					return
+				}
+			}
+			if tt.stopAfter == -1 {
+				t.Fatal("warmup error did not stop a run without a budget")
+			}
+			for i := 0; i < tt.ok; i++ {
+				if err := b.observe(nil, true); err != nil {
+					t.Fatalf("successful op stopped the run: %v", err)
+				}
+			}
+			for i := 1; i <= tt.fail; i++ {
+				err := b.observe(errFailed, true)
+				if (err != nil) != (i == tt.stopAfter) {
+					t.Fatalf("failed op %d: stop = %v, want stop only at %d", i, err, tt.stopAfter)
+				}
+				if err != nil {
+					if !errors.Is(err, errFailed) {
+						t.Errorf("stop error %v does not wrap the last error", err)
+					}
+					return
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package workload
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// keyspace hands out ids for inserts during a run and tracks the highest id
+// below which every insert has completed, so readers never pick an id whose
+// insert is still in flight.
+type keyspace struct {
+	next  int64
+	acked int64
+
+	mu      sync.Mutex
+	pending map[int64]struct{}
+}
+
+func newKeyspace(first int64) *keyspace {
+	return &keyspace{
+		next:    first - 1,
+		acked:   first - 1,
+		pending: make(map[int64]struct{}),
+	}
+}
+
+// Allocate returns the next unused id.
+func (k *keyspace) Allocate() int64 {
+	return atomic.AddInt64(&k.next, 1)
+}
+
+// Ack marks the insert of id as finished, successful or not.
+func (k *keyspace) Ack(id int64) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.pending[id] = struct{}{}
+	acked := atomic.LoadInt64(&k.acked)
+	for {
+		if _, ok := k.pending[acked+1]; !ok {
+			break
+		}
+		delete(k.pending, acked+1)
+		acked++
+	}
+	atomic.StoreInt64(&k.acked, acked)
+}
+
+// Latest returns the highest id whose insert, and every insert before it,
+// has finished.
+func (k *keyspace) Latest() int64 {
+	return atomic.LoadInt64(&k.acked)
+}
@@ -0,0 +1,71 @@
+package workload
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestKeyspaceAck(t *testing.T) {
+	tests := []struct {
+		name string
+		acks []int64
+		want []int64
+	}{
+		{"in order", []int64{101, 102, 103}, []int64{101, 102, 103}},
+		{"gap held back", []int64{102, 103, 101}, []int64{100, 100, 103}},
+		{"gap in the middle", []int64{101, 103, 104, 102}, []int64{101, 101, 101, 104}},
+		{"reversed", []int64{104, 103, 102, 101}, []int64{100, 100, 100, 104}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := newKeyspace(101)
+			if got := k.Latest(); got != 100 {
+				t.Fatalf("Latest() before any insert = %d, want 100", got)
+			}
+			for i := range tt.acks {
+				if got, want := k.Allocate(), int64(101+i); got != want {
+					t.Fatalf("Allocate() = %d, want %d", got, want)
+				}
+			}
+			for i, id := range tt.acks {
+				k.Ack(id)
+				if got := k.Latest(); got != tt.want[i] {
+					t.Errorf("Latest() after acking %v = %d, want %d", tt.acks[:i+1], got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestKeyspaceConcurrent(t *testing.T) {
+	const workers, per = 8, 1000
+	k := newKeyspace(1)
+	var wg sync.WaitGroup
+	seen := make([]map[int64]bool, workers)
+	for w := 0; w < workers; w++ {
+		seen[w] = make(map[int64]bool)
+		wg.Add(1)
+		go func(seen map[int64]bool) {
+			defer wg.Done()
+			for i := 0; i < per; i++ {
+				id := k.Allocate()
+				seen[id] = true
+				k.Ack(id)
+			}
+		}(seen[w])
+	}
+	wg.Wait()
+
+	all := make(map[int64]bool)
+	for _, s := range seen {
+		for id := range s {
+			if all[id] {
+				t.Fatalf("id %d allocated twice", id)
+			}
+			all[id] = true
+		}
+	}
+	if got := k.Latest(); got != workers*per {
+		t.Errorf("Latest() = %d, want %d", got, workers*per)
+	}
+}
@@ -0,0 +1,95 @@
+package workload
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// liveShards spreads the deleted set over several locks so that deletes
+// and reads from many workers do not serialize on one mutex.
+const liveShards = 64
+
+// liveKeys tracks the ids of a table that are deleted, or being deleted,
+// during a run. Reads and updates skip them, and inserts bring them back
+// before taking fresh ids, so the set stays as small as the churn allows.
+type liveKeys struct {
+	// free counts the deleted ids no insert has taken yet, so inserts
+	// skip the search while there are none.
+	free   atomic.Int64
+	shards [liveShards]struct {
+		mu sync.Mutex
+		// deleted maps each deleted id to whether a delete or insert
+		// in flight has taken it.
+		deleted map[int64]bool
+	}
+}
+
+func newLiveKeys() *liveKeys {
+	l := &liveKeys{}
+	for i := range l.shards {
+		l.shards[i].deleted = make(map[int64]bool)
+	}
+	return l
+}
+
+// MarkDeleted takes id for a delete and reports whether it was live.
+// Callers mark before issuing the delete, so a concurrent read that
+// already misses the row is not blamed, and Release it once the delete
+// is done so that no insert brings it back while the delete is in flight.
+func (l *liveKeys) MarkDeleted(id int64) bool {
+	s := &l.shards[uint64(id)%liveShards]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.deleted[id]; ok {
+		return false
+	}
+	s.deleted[id] = true
+	return true
+}
+
+// Deleted reports whether id was deleted, or is being deleted or
+// reinserted.
+func (l *liveKeys) Deleted(id int64) bool {
+	s := &l.shards[uint64(id)%liveShards]
+	s.mu.Lock()
+	_, ok := s.deleted[id]
+	s.mu.Unlock()
+	return ok
+}
+
+// Reclaim takes a deleted id for an insert to bring back, looking at the
+// shards from start on. ok is false when there is none. The id counts as
+// deleted until Released.
+func (l *liveKeys) Reclaim(start int) (id int64, ok bool) {
+	if l.free.Load() <= 0 {
+		return 0, false
+	}
+	for i := 0; i < liveShards; i++ {
+		s := &l.shards[(start+i)%liveShards]
+		s.mu.Lock()
+		for d, taken := range s.deleted {
+			if !taken {
+				s.deleted[d] = true
+				s.mu.Unlock()
+				l.free.Add(-1)
+				return d, true
+			}
+		}
+		s.mu.Unlock()
+	}
+	return 0, false
+}
+
+// Release ends the delete or insert that took id: live says whether the
+// row exists now. A deleted id is free for the next Reclaim.
+func (l *liveKeys) Release(id int64, live bool) {
+	s := &l.shards[uint64(id)%liveShards]
+	s.mu.Lock()
+	if live {
+		delete(s.deleted, id)
+	} else {
+		s.deleted[id] = false
+		l.free.Add(1)
+	}
+	s.mu.Unlock()
+}
@@ -0,0 +1,90 @@
+package workload
+
+import "testing"
+
+func TestLiveKeys(t *testing.T) {
+	type op struct {
+		do   string // "mark", "release", "reclaim"
+		id   int64
+		live bool // for release
+		ok   bool // what mark or reclaim reports
+	}
+	tests := []struct {
+		name    string
+		ops     []op
+		deleted []int64
+		live    []int64
+	}{
+		{
+			name: "delete then reinsert",
+			ops: []op{
+				{do: "mark", id: 5, ok: true},
+				{do: "reclaim", ok: false}, // still being deleted
+				{do: "release", id: 5, live: false},
+				{do: "reclaim", id: 5, ok: true},
+				{do: "reclaim", ok: false}, // taken by the insert
+				{do: "release", id: 5, live: true},
+			},
+			live: []int64{5},
+		},
+		{
+			name: "concurrent delete of the same id",
+			ops: []op{
+				{do: "mark", id: 7, ok: true},
+				{do: "mark", id: 7, ok: false},
+				{do: "release", id: 7, live: false},
+			},
+			deleted: []int64{7},
+		},
+		{
+			name: "failed reinsert frees the id again",
+			ops: []op{
+				{do: "mark", id: 3, ok: true},
+				{do: "release", id: 3, live: false},
+				{do: "reclaim", id: 3, ok: true},
+				{do: "release", id: 3, live: false},
+				{do: "reclaim", id: 3, ok: true},
+			},
+			deleted: []int64{3},
+		},
+		{
+			name: "failed delete leaves the row",
+			ops: []op{
+				{do: "mark", id: 9, ok: true},
+				{do: "release", id: 9, live: true},
+				{do: "reclaim", ok: false},
+			},
+			live: []int64{9},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLiveKeys()
+			for i, o := range tt.ops {
+				switch o.do {
+				case "mark":
+					if got := l.MarkDeleted(o.id); got != o.ok {
+						t.Fatalf("op %d: MarkDeleted(%d) = %v, want %v", i, o.id, got, o.ok)
+					}
+				case "release":
+					l.Release(o.id, o.live)
+				case "reclaim":
+					id, ok := l.Reclaim(i)
+					if ok != o.ok || (ok && id != o.id) {
+						t.Fatalf("op %d: Reclaim() = %d, %v, want %d, %v", i, id, ok, o.id, o.ok)
+					}
+				}
+			}
+			for _, id := range tt.deleted {
+				if !l.Deleted(id) {
+					t.Errorf("Deleted(%d) = false, want true", id)
+				}
+			}
+			for _, id := range tt.live {
+				if l.Deleted(id) {
+					t.Errorf("Deleted(%d) = true, want false", id)
+				}
+			}
+		})
+	}
+}
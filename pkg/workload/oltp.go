@@ -0,0 +1,94 @@
+package workload
+
+import (
+	"context"
+
+	"tidb-benchmarks/pkg/db/dberr"
+	"tidb-benchmarks/pkg/db/retry"
+	"tidb-benchmarks/pkg/db/txn"
+	"tidb-benchmarks/pkg/util"
+)
+
+// Statement counts of one oltp_read_write transaction, as in sysbench's
+// defaults.
+const (
+	oltpPointSelects    = 10
+	oltpIndexUpdates    = 1
+	oltpNonIndexUpdates = 1
+	oltpDeleteInserts   = 1
+)
+
+var oltpRanges = [...]txn.Range{txn.RangeSimple, txn.RangeSum, txn.RangeOrder, txn.RangeDistinct}
+
+// oltpQueries is what one transaction adds to the query count. Like
+// sysbench it counts BEGIN and COMMIT, so QPS lines up with its reports.
+const oltpQueries = oltpPointSelects + len(oltpRanges) + oltpIndexUpdates + oltpNonIndexUpdates + 2*oltpDeleteInserts + 2
+
+// oltp runs one oltp_read_write transaction starting with a point select
+// of id and returns the payload bytes moved. The range queries read
+// scan-length ids each. With retries the whole transaction is rerun after
+// a conflict on any of its statements or on the commit.
+func (r *runner) oltp(ctx context.Context, rng *util.SplitMix64, t *table, id int64) (n int, err error) {
+	rc, ok := r.client.(*retry.Client)
+	if !ok {
+		return r.oltpOnce(ctx, rng, t, id)
+	}
+	err = rc.Tx(ctx, func() (err error) {
+		n, err = r.oltpOnce(ctx, rng, t, id)
+		return err
+	})
+	return n, err
+}
+
+func (r *runner) oltpOnce(ctx context.Context, rng *util.SplitMix64, t *table, id int64) (n int, err error) {
+	tx, err := r.client.Begin(ctx, t.cfg)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for i := 0; i < oltpPointSelects; i++ {
+		if i > 0 {
+			id = t.keys.Next(rng)
+		}
+		payload, err := tx.Read(ctx, id)
+		// sysbench treats an empty result as a normal outcome.
+		if err != nil && r.client.ClassifyError(err) != dberr.ClassNotFound {
+			return n, err
+		}
+		n += len(payload)
+	}
+	for _, rq := range oltpRanges {
+		read, err := tx.RangeQuery(ctx, rq, t.keys.Next(rng), r.cfg.ScanLength)
+		n += read
+		if err != nil {
+			return n, err
+		}
+	}
+	for i := 0; i < oltpIndexUpdates; i++ {
+		if err := tx.UpdateK(ctx, t.keys.Next(rng), rng.Int63n(r.cfg.TableSize)); err != nil {
+			return n, err
+		}
+	}
+	for i := 0; i < oltpNonIndexUpdates; i++ {
+		if err := tx.UpdateC(ctx, t.keys.Next(rng), r.payload); err != nil {
+			return n, err
+		}
+		n += len(r.payload)
+	}
+	for i := 0; i < oltpDeleteInserts; i++ {
+		id := t.keys.Next(rng)
+		if err := tx.Delete(ctx, id); err != nil {
+			return n, err
+		}
+		if err := tx.Insert(ctx, id, rng.Int63n(r.cfg.TableSize), r.payload); err != nil {
+			return n, err
+		}
+		n += len(r.payload)
+	}
+	return n, tx.Commit()
+}
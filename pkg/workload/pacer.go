@@ -0,0 +1,46 @@
+package workload
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// pacer hands out intended start times for an open-loop run. Slots are
+// spaced evenly at the target rate and shared across workers, so a stalled
+// database makes later operations start late instead of silently lowering
+// the offered load.
+type pacer struct {
+	begin    time.Time
+	interval time.Duration
+	slot     int64
+}
+
+func newPacer(begin time.Time, rate float64) *pacer {
+	interval := time.Duration(float64(time.Second) / rate)
+	if interval <= 0 {
+		interval = 1
+	}
+	return &pacer{begin: begin, interval: interval}
+}
+
+// Next returns the intended start time of the next unclaimed slot.
+func (p *pacer) Next() time.Time {
+	slot := atomic.AddInt64(&p.slot, 1) - 1
+	return p.begin.Add(time.Duration(slot) * p.interval)
+}
+
+func sleepUntil(ctx context.Context, t time.Time) error {
+	d := time.Until(t)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
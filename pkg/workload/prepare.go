@@ -3,6 +3,8 @@ package workload
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -15,6 +17,12 @@ import (
 	"tidb-benchmarks/pkg/util"
 )
 
+// maxAutoChunkSize caps the chunk size picked when --chunk-size is 0.
+const maxAutoChunkSize = 1000000
+
+// Prepare loads ids [1, cfg.TableSize], or the --id-range slice of them, in
+// chunks into every table. Chunk progress is logged to a checkpoint file
+// per table so that --resume can reload only what is missing.
 func Prepare(ctx context.Context, client db.Client, cfg config.Config) (metrics.Summary, error) {
 	if cfg.TableSize <= 0 {
 		return metrics.Summary{}, fmt.Errorf("table-size must be > 0")
@@ -22,14 +30,65 @@ func Prepare(ctx context.Context, client db.Client, cfg config.Config) (metrics.
 	if cfg.Threads <= 0 {
 		return metrics.Summary{}, fmt.Errorf("threads must be > 0")
 	}
-
-	if err := client.PrepareSchema(ctx, cfg); err != nil {
+	if cfg.ChunkSize < 0 {
+		return metrics.Summary{}, fmt.Errorf("chunk-size must be >= 0")
+	}
+	batch := cfg.BatchSize
+	if batch <= 0 {
+		batch = 1
+	}
+	lo, hi, err := parseIDRange(cfg.IDRange, cfg.TableSize)
+	if err != nil {
 		return metrics.Summary{}, err
 	}
-	if err := client.Truncate(ctx, cfg); err != nil {
+
+	cfgs, err := tableConfigs(cfg)
+	if err != nil {
 		return metrics.Summary{}, err
 	}
 
+	// Every table is split into chunks the same way; the workers share one
+	// queue of pending chunks across all tables.
+	type task struct {
+		table int
+		chunk int64
+	}
+	var pending []task
+	ckpts := make([]*checkpoint, len(cfgs))
+	for i, tc := range cfgs {
+		if err := client.PrepareSchema(ctx, tc); err != nil {
+			return metrics.Summary{}, err
+		}
+		// A resumed load keeps what is there, and a slice of a table shared
+		// with other loaders must not wipe their rows.
+		if !cfg.Resume && cfg.IDRange == "" {
+			if err := client.Truncate(ctx, tc); err != nil {
+				return metrics.Summary{}, err
+			}
+		}
+
+		header := checkpointHeader{Table: tc.Table, Start: lo, End: hi, ChunkSize: cfg.ChunkSize}
+		if header.ChunkSize == 0 && !cfg.Resume {
+			header.ChunkSize = autoChunkSize((hi-lo+1)*int64(len(cfgs)), cfg.Threads, batch)
+		}
+		var chunks int64
+		if header.ChunkSize > 0 {
+			chunks = (hi - lo + header.ChunkSize) / header.ChunkSize
+		}
+		ckpt, err := openCheckpoint(checkpointPath(tc, len(cfgs) > 1, lo, hi, chunks), header, cfg.Resume)
+		if err != nil {
+			return metrics.Summary{}, err
+		}
+		defer ckpt.Close()
+		ckpts[i] = ckpt
+
+		for chunk := int64(0); chunk < ckpt.Chunks(); chunk++ {
+			if ckpt.State(chunk) != chunkDone {
+				pending = append(pending, task{table: i, chunk: chunk})
+			}
+		}
+	}
+
 	payload := util.MakePayload(cfg.PayloadSize)
 
 	var mu sync.Mutex
@@ -37,7 +96,7 @@ func Prepare(ctx context.Context, client db.Client, cfg config.Config) (metrics.
 	start := time.Now()
 	global.Start(start)
 
-	var nextID int64
+	var next int64
 	eg, egctx := errgroup.WithContext(ctx)
 	eg.SetLimit(cfg.Threads)
 
@@ -47,20 +106,58 @@ func Prepare(ctx context.Context, client db.Client, cfg config.Config) (metrics.
 			rng := util.NewSplitMix64(uint64(time.Now().UnixNano()) + uint64(workerID)*7919)
 			local := metrics.NewRecorder()
 			local.Start(start)
+			defer func() {
+				local.End(time.Now())
+				mu.Lock()
+				global.Merge(local)
+				mu.Unlock()
+			}()
+
+			ks := make([]int64, batch)
 			for {
-				id := atomic.AddInt64(&nextID, 1)
-				if id > cfg.TableSize {
-					local.End(time.Now())
-					mu.Lock()
-					global.Merge(local)
-					mu.Unlock()
+				idx := atomic.AddInt64(&next, 1) - 1
+				if idx >= int64(len(pending)) {
 					return nil
 				}
-				k := rng.Int63n(cfg.TableSize)
-				t0 := time.Now()
-				err := client.Insert(egctx, cfg, id, k, payload)
-				local.Record(time.Since(t0), len(payload), err == nil)
-				if err != nil {
+				tc, ckpt, chunk := cfgs[pending[idx].table], ckpts[pending[idx].table], pending[idx].chunk
+				first, last := ckpt.Bounds(chunk)
+
+				// A chunk that was started but not finished may hold some
+				// of its rows already, and so may a slice loaded before,
+				// since --id-range does not truncate.
+				if ckpt.State(chunk) == chunkStarted || (cfg.IDRange != "" && !cfg.Resume) {
+					if err := client.DeleteRange(egctx, tc, first, last); err != nil {
+						return err
+					}
+				}
+				if err := ckpt.Mark(chunk, chunkStarted); err != nil {
+					return err
+				}
+
+				for id := first; id <= last; id += int64(batch) {
+					n := batch
+					if rest := last - id + 1; rest < int64(n) {
+						n = int(rest)
+					}
+					for j := 0; j < n; j++ {
+						ks[j] = rng.Int63n(cfg.TableSize)
+					}
+
+					var err error
+					t0 := time.Now()
+					if batch == 1 {
+						err = client.Insert(egctx, tc, id, ks[0], payload)
+					} else {
+						err = client.InsertBatch(egctx, tc, id, ks[:n], payload)
+					}
+					local.Record(time.Since(t0), n*len(payload), err == nil)
+					if err != nil {
+						return err
+					}
+					local.AddRows(n)
+				}
+
+				if err := ckpt.Mark(chunk, chunkDone); err != nil {
 					return err
 				}
 			}
@@ -69,8 +166,92 @@ func Prepare(ctx context.Context, client db.Client, cfg config.Config) (metrics.
 
 	if err := eg.Wait(); err != nil {
 		global.End(time.Now())
-		return global.Summary(fmt.Sprintf("prepare/%s", client.Name())), err
+		return prepareSummary(global, cfg, client), err
 	}
 	global.End(time.Now())
-	return global.Summary(fmt.Sprintf("prepare/%s", client.Name())), nil
+	return prepareSummary(global, cfg, client), nil
+}
+
+func prepareSummary(r *metrics.Recorder, cfg config.Config, client db.Client) metrics.Summary {
+	s := r.Summary(fmt.Sprintf("prepare/%s", client.Name()))
+	s.Settings = db.Settings(cfg)
+	if s.Settings == nil {
+		s.Settings = make(map[string]string)
+	}
+	if cfg.BatchSize > 1 {
+		s.Settings["batch_size"] = strconv.Itoa(cfg.BatchSize)
+	}
+	if cfg.IDRange != "" {
+		s.Settings["id_range"] = cfg.IDRange
+	}
+	if cfg.Resume {
+		s.Settings["resumed"] = "true"
+	}
+	if cfg.Tables > 0 {
+		s.Settings["tables"] = strconv.Itoa(cfg.Tables)
+	}
+	if len(s.Settings) == 0 {
+		s.Settings = nil
+	}
+	return s
+}
+
+// parseIDRange parses "start:end" (inclusive) within [1, tableSize]. An
+// empty spec is the whole table.
+func parseIDRange(spec string, tableSize int64) (int64, int64, error) {
+	if spec == "" {
+		return 1, tableSize, nil
+	}
+	a, b, ok := strings.Cut(spec, ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("id-range must be start:end, got %q", spec)
+	}
+	lo, err := strconv.ParseInt(strings.TrimSpace(a), 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("id-range start: %w", err)
+	}
+	hi, err := strconv.ParseInt(strings.TrimSpace(b), 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("id-range end: %w", err)
+	}
+	if lo < 1 || hi > tableSize || lo > hi {
+		return 0, 0, fmt.Errorf("id-range must satisfy 1 <= start <= end <= table-size, got %q", spec)
+	}
+	return lo, hi, nil
+}
+
+// autoChunkSize gives every worker several chunks so that a resume loses
+// little work, without making the checkpoint log huge.
+func autoChunkSize(rows int64, threads, batch int) int64 {
+	size := rows / int64(threads*8)
+	if size < int64(batch) {
+		size = int64(batch)
+	}
+	if size > maxAutoChunkSize {
+		size = maxAutoChunkSize
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// checkpointPath names the checkpoint of one table, whose load takes
+// chunks chunks. An explicit --checkpoint gets the table name appended when
+// several tables load. A plain load that fits in one chunk has nothing to
+// resume, keeps no checkpoint and gets "".
+func checkpointPath(cfg config.Config, multi bool, lo, hi, chunks int64) string {
+	if cfg.Checkpoint == "" && cfg.IDRange == "" && !cfg.Resume && chunks <= 1 {
+		return ""
+	}
+	if cfg.Checkpoint != "" {
+		if multi {
+			return cfg.Checkpoint + "." + cfg.Table
+		}
+		return cfg.Checkpoint
+	}
+	if cfg.IDRange != "" {
+		return fmt.Sprintf("%s.%d-%d.checkpoint", cfg.Table, lo, hi)
+	}
+	return cfg.Table + ".checkpoint"
 }
@@ -0,0 +1,37 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/db"
+	"tidb-benchmarks/pkg/metrics"
+)
+
+// Repeat runs kind --repeat times on the same client, pausing --cooldown
+// between runs, each with its own warmup and --timeout. It stops at the
+// first failed run and returns the runs that completed along with the
+// error.
+func Repeat(ctx context.Context, client db.Client, cfg config.Config, kind Kind) (metrics.Repeated, error) {
+	if cfg.Repeat < 1 || cfg.RepeatCooldown < 0 {
+		return metrics.Repeated{}, fmt.Errorf("repeat must be >= 1 and cooldown >= 0")
+	}
+	var runs []metrics.Summary
+	for i := 0; i < cfg.Repeat; i++ {
+		if i > 0 && cfg.RepeatCooldown > 0 {
+			if err := sleepUntil(ctx, time.Now().Add(cfg.RepeatCooldown)); err != nil {
+				return metrics.NewRepeated(runs, cfg.RepeatCooldown), err
+			}
+		}
+		runCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+		s, err := Run(runCtx, client, cfg, kind)
+		cancel()
+		if err != nil {
+			return metrics.NewRepeated(runs, cfg.RepeatCooldown), fmt.Errorf("repeat %d: %w", i+1, err)
+		}
+		runs = append(runs, s)
+	}
+	return metrics.NewRepeated(runs, cfg.RepeatCooldown), nil
+}
@@ -3,6 +3,7 @@ package workload
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
@@ -10,6 +11,9 @@ import (
 
 	"tidb-benchmarks/pkg/config"
 	"tidb-benchmarks/pkg/db"
+	"tidb-benchmarks/pkg/db/dberr"
+	"tidb-benchmarks/pkg/db/retry"
+	"tidb-benchmarks/pkg/keydist"
 	"tidb-benchmarks/pkg/metrics"
 	"tidb-benchmarks/pkg/util"
 )
@@ -24,22 +28,118 @@ func Run(ctx context.Context, client db.Client, cfg config.Config, kind Kind) (m
 	if cfg.Time <= 0 {
 		return metrics.Summary{}, fmt.Errorf("time must be > 0")
 	}
+	if cfg.Rate < 0 {
+		return metrics.Summary{}, fmt.Errorf("rate must be >= 0")
+	}
+	if cfg.MaxErrors < 0 || cfg.MaxErrorRate < 0 || cfg.MaxErrorRate > 1 {
+		return metrics.Summary{}, fmt.Errorf("max-errors must be >= 0 and max-error-rate in [0, 1]")
+	}
 
-	if err := client.PrepareSchema(ctx, cfg); err != nil {
+	m, err := mixFor(kind, cfg)
+	if err != nil {
 		return metrics.Summary{}, err
 	}
+	switch kind {
+	case KindBank:
+		if cfg, err = bankConfig(cfg); err != nil {
+			return metrics.Summary{}, err
+		}
+	case KindCAS:
+		if cfg, err = casConfig(cfg); err != nil {
+			return metrics.Summary{}, err
+		}
+	}
+	if (m.scan > 0 || m.transaction > 0) && cfg.ScanLength <= 0 {
+		return metrics.Summary{}, fmt.Errorf("scan-length must be > 0")
+	}
+	if cfg.Verify && cfg.PayloadSize < versionHeader {
+		return metrics.Summary{}, fmt.Errorf("verify needs payload-size >= %d", versionHeader)
+	}
+	// Every worker needs an id of its own to write; cfg.TableSize is
+	// --cas-keys for cas by now.
+	if cfg.Verify && int64(cfg.Threads) > cfg.TableSize {
+		return metrics.Summary{}, fmt.Errorf("verify needs threads <= %d, the rows in the table", cfg.TableSize)
+	}
+	policy, err := retry.NewPolicy(cfg)
+	if err != nil {
+		return metrics.Summary{}, err
+	}
+	if m.indexLookup > 0 && cfg.KIndex == "" {
+		return metrics.Summary{}, fmt.Errorf("%s needs a secondary index, set --k-index", kind)
+	}
 
-	readRatio := clampRatio(cfg.ReadRatio)
-	warmup := effectiveWarmup(cfg.Warmup)
+	cfgs, err := tableConfigs(cfg)
+	if err != nil {
+		return metrics.Summary{}, err
+	}
+	for _, tc := range cfgs {
+		if err := client.PrepareSchema(ctx, tc); err != nil {
+			return metrics.Summary{}, err
+		}
+	}
 
-	payload := util.MakePayload(cfg.PayloadSize)
+	starts := make([]int64, len(cfgs))
+	for i, tc := range cfgs {
+		if starts[i], err = insertStart(ctx, client, tc, m); err != nil {
+			return metrics.Summary{}, err
+		}
+	}
+	tables, err := newTables(cfgs, starts)
+	if err != nil {
+		return metrics.Summary{}, err
+	}
+
+	r := &runner{
+		client:  client,
+		cfg:     cfg,
+		payload: util.MakePayload(cfg.PayloadSize),
+		errs:    newErrorBudget(cfg),
 
-	endWarmup := time.Now().Add(warmup)
+		scanUniform: m.scanUniform,
+	}
+	switch kind {
+	case KindBank:
+		if err := loadBank(ctx, client, cfg, r.payload); err != nil {
+			return metrics.Summary{}, err
+		}
+	case KindCAS:
+		if err := loadConstant(ctx, client, cfg, 0, r.payload); err != nil {
+			return metrics.Summary{}, err
+		}
+	}
+	warmup := effectiveWarmup(cfg.Warmup)
+
+	begin := time.Now()
+	endWarmup := begin.Add(warmup)
 	startMeasure := endWarmup
 	endMeasure := startMeasure.Add(cfg.Time)
 
+	var bank *bankChecker
+	stopChecks := func() {}
+	if kind == KindBank {
+		bank = newBankChecker(client, cfg, begin)
+		stopChecks = bank.run(ctx)
+	}
+
+	// With a target rate the run is open-loop: operations are scheduled at
+	// fixed intended start times and latency is measured from those, which
+	// corrects for coordinated omission.
+	var pace *pacer
+	if cfg.Rate > 0 {
+		pace = newPacer(begin, cfg.Rate)
+	}
+
 	var mu sync.Mutex
 	global := metrics.NewRecorder()
+	verified := metrics.NewVerifyRecorder()
+	retried := metrics.NewRetryRecorder()
+
+	var intervals *metrics.IntervalCollector
+	stopReporting := func() {}
+	if cfg.ReportInterval > 0 {
+		intervals = metrics.NewIntervalCollector(cfg.Threads, startMeasure)
+		stopReporting = collectIntervals(intervals, cfg, startMeasure, endMeasure)
+	}
 
 	eg, egctx := errgroup.WithContext(ctx)
 	eg.SetLimit(cfg.Threads)
@@ -49,55 +149,153 @@ func Run(ctx context.Context, client db.Client, cfg config.Config, kind Kind) (m
 		eg.Go(func() error {
 			rng := util.NewSplitMix64(uint64(time.Now().UnixNano()) + uint64(workerID)*104729)
 			local := metrics.NewRecorder()
+			// Each worker gets its own retrying client, which is not
+			// safe for concurrent use.
+			w := r
+			var rc *retry.Client
+			if policy.Enabled() {
+				rc = retry.New(r.client, policy, rng.Next())
+				wr := *r
+				wr.client = rc
+				w = &wr
+			}
+			var v *verifier
+			if cfg.Verify {
+				v = newVerifier(workerID, cfg.Threads, r.payload)
+			}
 			measuring := false
+			var window *metrics.Window
+			if intervals != nil {
+				window = intervals.Window(workerID)
+			}
 
 			for {
 				now := time.Now()
+				if pace != nil {
+					now = pace.Next()
+				}
 				if now.After(endMeasure) {
 					break
 				}
+				if pace != nil {
+					if err := sleepUntil(egctx, now); err != nil {
+						return err
+					}
+				}
 				if !measuring && now.After(endWarmup) {
 					measuring = true
 					local.Start(startMeasure)
+					if v != nil {
+						// Keep what warmup saw, count only from here.
+						v.rec = metrics.NewVerifyRecorder()
+					}
+					if rc != nil {
+						rc.Reset()
+					}
 				}
 
-				id := 1 + rng.Int63n(cfg.TableSize)
-				k := rng.Int63n(cfg.TableSize)
-
-				doRead := false
-				switch kind {
-				case KindReadOnly:
-					doRead = true
-				case KindWriteOnly:
-					doRead = false
-				case KindMixed:
-					doRead = float64(rng.Next()%10000)/10000.0 < readRatio
-				default:
-					return fmt.Errorf("unsupported workload kind: %s", kind)
+				o := m.pick(rng)
+				t := tables[0]
+				if len(tables) > 1 {
+					t = tables[rng.Int63n(int64(len(tables)))]
 				}
-
-				if doRead {
-					t0 := time.Now()
-					payloadOut, err := client.Read(egctx, cfg, id)
-					if measuring {
-						local.Record(time.Since(t0), len(payloadOut), err == nil)
+				id := t.keys.Next(rng)
+				switch o {
+				case metrics.OpRead, metrics.OpUpdate, metrics.OpReadModifyWrite:
+					// Prefer an id that still exists: reading a
+					// deleted row measures little, and an update
+					// would bring it back on Cassandra.
+					for try := 0; try < maxDeletePicks && t.live.Deleted(id); try++ {
+						id = t.keys.Next(rng)
 					}
-					if err != nil {
-						return err
+					if t.live.Deleted(id) {
+						// Nearly everything is deleted; skip
+						// rather than measure a miss.
+						continue
+					}
+				case metrics.OpDelete:
+					// Take the id before deleting it, so that no
+					// insert brings it back and no other delete
+					// races this one while it is in flight.
+					taken := t.live.MarkDeleted(id)
+					for try := 0; try < maxDeletePicks && !taken; try++ {
+						id = t.keys.Next(rng)
+						taken = t.live.MarkDeleted(id)
+					}
+					if !taken {
+						continue
 					}
-					continue
 				}
 
 				t0 := time.Now()
-				err := client.Update(egctx, cfg, id, k, payload)
+				var (
+					n, attempts int
+					rows        int
+					applied     bool
+					err         error
+				)
+				switch o {
+				case metrics.OpCAS:
+					attempts, applied, err = w.cas(egctx, t, id)
+					if applied {
+						n = len(r.payload)
+					}
+				case metrics.OpScan:
+					rows, n, err = w.scan(egctx, rng, t, id)
+				case metrics.OpIndexLookup:
+					// k values are drawn at random from [0, table-size),
+					// so the key distribution picks a k value that may
+					// match no row or several.
+					rows, n, err = w.client.ReadByK(egctx, t.cfg, id-1)
+				default:
+					n, err = w.do(egctx, rng, v, t, o, id)
+				}
 				if measuring {
-					local.Record(time.Since(t0), len(payload), err == nil)
+					if o == metrics.OpTransaction && err == nil {
+						local.AddQueries(oltpQueries)
+					}
+					if o == metrics.OpCAS && err == nil {
+						local.RecordCAS(attempts, applied)
+					}
+					if (o == metrics.OpScan || o == metrics.OpIndexLookup) && err == nil {
+						local.RecordRows(o, rows)
+					}
+					if len(tables) > 1 {
+						local.CountTable(t.cfg.Table)
+					}
+					latency := time.Since(t0)
+					if pace != nil {
+						service := latency
+						latency = time.Since(now)
+						local.RecordOpIntended(o, latency, service, n, err == nil)
+					} else {
+						local.RecordOp(o, latency, n, err == nil)
+					}
+					if window != nil {
+						window.Record(latency, err == nil)
+					}
+					if err != nil {
+						local.RecordErrorClass(string(client.ClassifyError(err)))
+					}
+				}
+				if err != nil && egctx.Err() != nil {
+					return err
 				}
-				if err != nil {
+				if err := r.errs.observe(err, measuring); err != nil {
 					return err
 				}
 			}
 
+			if v != nil {
+				mu.Lock()
+				verified.Merge(v.rec)
+				mu.Unlock()
+			}
+			if rc != nil && measuring {
+				mu.Lock()
+				retried.Merge(rc.Recorder())
+				mu.Unlock()
+			}
 			if measuring {
 				local.End(endMeasure)
 				mu.Lock()
@@ -108,9 +306,29 @@ func Run(ctx context.Context, client db.Client, cfg config.Config, kind Kind) (m
 		})
 	}
 
-	if err := eg.Wait(); err != nil {
+	err = eg.Wait()
+	stopReporting()
+	stopChecks()
+	var verify *metrics.Verification
+	if cfg.Verify {
+		verify = verified.Result(db.ReadConsistency(cfg))
+	}
+	var retries *metrics.Retries
+	if policy.Enabled() {
+		retries = retried.Result(policy.Attempts, policy.Classes())
+	}
+	if bank != nil {
+		// The final check sees every transfer that finished.
+		if cerr := bank.check(ctx, time.Now(), true); cerr != nil && err == nil {
+			err = fmt.Errorf("final balance check: %w", cerr)
+		}
+	}
+	if err != nil {
 		global.End(time.Now())
-		return global.Summary(fmt.Sprintf("%s/%s", kind, client.Name())), err
+		s := runSummary(global, intervals, bank, cfg, kind, client)
+		s.Verify = verify
+		s.Retry = retries
+		return s, err
 	}
 
 	if global.Summary("tmp").Ops == 0 {
@@ -118,5 +336,222 @@ func Run(ctx context.Context, client db.Client, cfg config.Config, kind Kind) (m
 		global.Start(startMeasure)
 		global.End(endMeasure)
 	}
-	return global.Summary(fmt.Sprintf("%s/%s", kind, client.Name())), nil
+	s := runSummary(global, intervals, bank, cfg, kind, client)
+	s.Verify = verify
+	s.Retry = retries
+	return s, nil
+}
+
+// insertStart returns the first id inserts take in the table of cfg:
+// --insert-start, or the id after the highest one the table holds, so that
+// a run after one that inserted, or the next repeat, sweep step or phase,
+// does not collide with the rows inserted before.
+func insertStart(ctx context.Context, client db.Client, cfg config.Config, m mix) (int64, error) {
+	if cfg.InsertStart > 0 {
+		return cfg.InsertStart, nil
+	}
+	if m.insert == 0 {
+		return cfg.TableSize + 1, nil
+	}
+	maxID, err := client.MaxID(ctx, cfg)
+	if err != nil {
+		return 0, fmt.Errorf("read highest id of %s: %w", cfg.Table, err)
+	}
+	return max(maxID, cfg.TableSize) + 1, nil
+}
+
+func runSummary(r *metrics.Recorder, intervals *metrics.IntervalCollector, bank *bankChecker, cfg config.Config, kind Kind, client db.Client) metrics.Summary {
+	s := r.Summary(fmt.Sprintf("%s/%s", kind, client.Name()))
+	s.Settings = db.Settings(cfg)
+	s.KeyDist, s.KeyDistParams = keydist.Describe(cfg)
+	s.TargetRate = cfg.Rate
+	if kind == KindOLTPReadWrite {
+		if s.Settings == nil {
+			s.Settings = make(map[string]string)
+		}
+		s.Settings["tx"] = db.TxSemantics(cfg)
+	}
+	if cfg.Tables > 0 {
+		if s.Settings == nil {
+			s.Settings = make(map[string]string)
+		}
+		s.Settings["tables"] = strconv.Itoa(cfg.Tables)
+	}
+	if intervals != nil {
+		s.Intervals = intervals.Intervals()
+	}
+	if bank != nil {
+		s.Invariant = bank.result()
+		if cfg.BankMode != "" {
+			if s.Settings == nil {
+				s.Settings = make(map[string]string)
+			}
+			s.Settings["bank_mode"] = cfg.BankMode
+		}
+	}
+	return s
+}
+
+// collectIntervals collects an interval every cfg.ReportInterval from
+// start until end and passes each to cfg.OnInterval. The returned stop
+// func collects the partial interval since the last one, if the run ended
+// between two, waits for the collector to exit and must be called before
+// reading the collected intervals.
+func collectIntervals(c *metrics.IntervalCollector, cfg config.Config, start, end time.Time) (stop func()) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	last := start
+	collect := func(now time.Time) {
+		if now.After(end) {
+			now = end
+		}
+		if !now.After(last) {
+			return
+		}
+		last = now
+		iv := c.Collect(now)
+		if cfg.OnInterval != nil {
+			cfg.OnInterval(iv)
+		}
+	}
+	go func() {
+		defer close(exited)
+		select {
+		case <-done:
+			collect(time.Now())
+			return
+		case <-time.After(time.Until(start)):
+		}
+		ticker := time.NewTicker(cfg.ReportInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				collect(time.Now())
+				return
+			case now := <-ticker.C:
+				collect(now)
+				if !now.Before(end) {
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// maxDeletePicks bounds how often an operation redraws an id that is
+// deleted.
+const maxDeletePicks = 8
+
+// runner holds what every worker shares while executing operations.
+type runner struct {
+	client  db.Client
+	cfg     config.Config
+	payload []byte
+	errs    *errorBudget
+
+	scanUniform bool
+}
+
+// do executes one operation against id in table t and returns the payload
+// bytes moved. v is the worker's verifier with --verify, else nil.
+func (r *runner) do(ctx context.Context, rng *util.SplitMix64, v *verifier, t *table, o metrics.Op, id int64) (int, error) {
+	cfg := t.cfg
+	k := rng.Int63n(r.cfg.TableSize)
+	switch o {
+	case metrics.OpRead:
+		payloadOut, err := r.read(ctx, v, t, id)
+		return len(payloadOut), r.deletedMiss(t, id, err)
+	case metrics.OpUpdate:
+		if v != nil {
+			id = v.own(id, r.cfg.TableSize)
+		}
+		return len(r.payload), r.update(ctx, v, t, id, k)
+	case metrics.OpInsert:
+		if old, ok := t.live.Reclaim(int(rng.Int63n(liveShards))); ok {
+			err := r.client.Insert(ctx, cfg, old, k, r.payload)
+			t.live.Release(old, err == nil)
+			return len(r.payload), err
+		}
+		newID := t.ks.Allocate()
+		err := r.client.Insert(ctx, cfg, newID, k, r.payload)
+		if err != nil {
+			// The row is missing, so readers must skip the id rather
+			// than count every read of it as an error; a later insert
+			// takes it again.
+			t.live.MarkDeleted(newID)
+			t.live.Release(newID, false)
+		}
+		t.ks.Ack(newID)
+		return len(r.payload), err
+	case metrics.OpDelete:
+		// The worker took id for this delete.
+		err := r.client.Delete(ctx, cfg, id)
+		t.live.Release(id, false)
+		return 0, err
+	case metrics.OpTransaction:
+		return r.oltp(ctx, rng, t, id)
+	case metrics.OpTransfer:
+		return 0, r.client.Transfer(ctx, cfg, id, t.keys.Next(rng), 1+rng.Int63n(bankMaxTransfer))
+	case metrics.OpReadModifyWrite:
+		if v != nil {
+			id = v.own(id, r.cfg.TableSize)
+		}
+		payloadOut, err := r.read(ctx, v, t, id)
+		if err != nil {
+			return len(payloadOut), r.deletedMiss(t, id, err)
+		}
+		return len(payloadOut) + len(r.payload), r.update(ctx, v, t, id, k)
+	default:
+		return 0, fmt.Errorf("unsupported operation: %s", o)
+	}
+}
+
+// scan reads scan-length rows from id, or a uniform 1..scan-length for
+// YCSB E, and returns the rows and payload bytes read.
+func (r *runner) scan(ctx context.Context, rng *util.SplitMix64, t *table, id int64) (rows, n int, err error) {
+	limit := r.cfg.ScanLength
+	if r.scanUniform {
+		limit = 1 + int(rng.Int63n(int64(r.cfg.ScanLength)))
+	}
+	return r.client.Scan(ctx, t.cfg, id, limit)
+}
+
+// read reads id and, with --verify, checks the version it got.
+func (r *runner) read(ctx context.Context, v *verifier, t *table, id int64) ([]byte, error) {
+	start := time.Now()
+	payload, err := r.client.Read(ctx, t.cfg, id)
+	if v != nil && err == nil {
+		v.check(t, id, payload, start)
+	}
+	return payload, err
+}
+
+// update writes id and, with --verify, stamps and records a new version.
+func (r *runner) update(ctx context.Context, v *verifier, t *table, id, k int64) error {
+	if v == nil {
+		return r.client.Update(ctx, t.cfg, id, k, r.payload)
+	}
+	ver, payload := v.stamp()
+	if err := r.client.Update(ctx, t.cfg, id, k, payload); err != nil {
+		return err
+	}
+	t.versions.ack(id, ackedWrite{version: ver, at: time.Now()})
+	return nil
+}
+
+// deletedMiss drops the error of a read that found nothing because the run
+// deleted id, unless --count-deleted-reads asks to count those.
+func (r *runner) deletedMiss(t *table, id int64, err error) error {
+	if err == nil || r.cfg.CountDeletedReads {
+		return err
+	}
+	if r.client.ClassifyError(err) == dberr.ClassNotFound && t.live.Deleted(id) {
+		return nil
+	}
+	return err
 }
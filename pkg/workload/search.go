@@ -0,0 +1,150 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/db"
+	"tidb-benchmarks/pkg/metrics"
+)
+
+// maxSearchTrials bounds a search that keeps passing without --search-max.
+const maxSearchTrials = 32
+
+// maxSearchThreads caps a thread count search without --search-max.
+const maxSearchThreads = 4096
+
+// Search finds the highest --rate (or thread count) at which kind meets
+// the p99 objective, with short trial runs on the same client. Without
+// --search-max it doubles from --search-min until a trial fails, then
+// bisects between the last pass and the first failure until they are
+// within --search-precision of each other. Thread counts double up to
+// 4096 at most.
+func Search(ctx context.Context, client db.Client, cfg config.Config, kind Kind) (metrics.Search, error) {
+	threads := cfg.SearchParam == "threads"
+	if !threads && cfg.SearchParam != "rate" {
+		return metrics.Search{}, fmt.Errorf("unsupported search-param: %s", cfg.SearchParam)
+	}
+	if cfg.SLOP99 <= 0 {
+		return metrics.Search{}, fmt.Errorf("slo-p99 must be > 0")
+	}
+	if cfg.SearchMin <= 0 || (cfg.SearchMax != 0 && cfg.SearchMax < cfg.SearchMin) {
+		return metrics.Search{}, fmt.Errorf("search-min must be > 0 and search-max 0 or >= search-min")
+	}
+	if cfg.SearchPrecision <= 0 || cfg.SearchTrialTime <= 0 {
+		return metrics.Search{}, fmt.Errorf("search-precision and trial-time must be > 0")
+	}
+	cfg.Time = cfg.SearchTrialTime
+
+	res := metrics.Search{
+		Param:    cfg.SearchParam,
+		SLOP99Ms: float64(cfg.SLOP99.Microseconds()) / 1000,
+	}
+	// trial runs one value and records it; err is only set when the run
+	// could not start, which would fail every trial alike.
+	trial := func(v float64) (bool, error) {
+		step := cfg
+		if threads {
+			step.Threads = int(v)
+		} else {
+			step.Rate = v
+		}
+		trialCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+		s, err := Run(trialCtx, client, step, kind)
+		cancel()
+		if err != nil && (s.Ops == 0 || ctx.Err() != nil) {
+			return false, fmt.Errorf("%s %g: %w", res.Param, v, err)
+		}
+		t := metrics.Trial{
+			Value:   v,
+			QPS:     s.QPS,
+			P99Ms:   s.P99Ms,
+			Errors:  s.Errors,
+			Pass:    err == nil && s.P99Ms <= res.SLOP99Ms,
+			Summary: s,
+		}
+		if err != nil {
+			t.Error = err.Error()
+		}
+		res.Name = s.Name
+		res.Trials = append(res.Trials, t)
+		if t.Pass && v > res.Best {
+			res.Best, res.BestQPS = v, s.QPS
+		}
+		return t.Pass, nil
+	}
+
+	capped, err := probe(cfg.SearchMin, cfg.SearchMax, cfg.SearchPrecision, threads, trial)
+	res.Capped = capped
+	return res, err
+}
+
+// probe runs the trials of Search: trial runs one value and reports
+// whether it passed. capped reports that the highest value probe may try
+// passed, so the limit lies beyond it. Without to it doubles from from.
+func probe(from, to, precision float64, threads bool, trial func(v float64) (bool, error)) (capped bool, err error) {
+	round := func(v float64) float64 {
+		if threads {
+			return math.Round(v)
+		}
+		return v
+	}
+	trials := 0
+	run := func(v float64) (bool, error) {
+		trials++
+		return trial(v)
+	}
+
+	lo := round(from)
+	ok, err := run(lo)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	// Find a failing upper bound.
+	hi := round(to)
+	if hi > 0 {
+		if hi > lo {
+			if ok, err = run(hi); err != nil {
+				return false, err
+			}
+		}
+		if ok {
+			return true, nil
+		}
+	} else {
+		for hi = round(lo * 2); ; hi = round(hi * 2) {
+			if threads && hi > maxSearchThreads {
+				hi = maxSearchThreads
+			}
+			if hi <= lo || trials >= maxSearchTrials {
+				return true, nil
+			}
+			if ok, err = run(hi); err != nil {
+				return false, err
+			}
+			if !ok {
+				break
+			}
+			lo = hi
+		}
+	}
+
+	for hi-lo > precision*hi && trials < maxSearchTrials {
+		mid := round((lo + hi) / 2)
+		if mid <= lo || mid >= hi {
+			break
+		}
+		if ok, err = run(mid); err != nil {
+			return false, err
+		}
+		if ok {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,106 @@
+package workload
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestProbe(t *testing.T) {
+	tests := []struct {
+		name      string
+		from, to  float64
+		precision float64
+		threads   bool
+		// limit is the highest value that passes.
+		limit  float64
+		tried  []float64
+		capped bool
+	}{
+		{
+			name: "doubles then bisects", from: 100, precision: 0.05, limit: 700,
+			tried: []float64{100, 200, 400, 800, 600, 700, 750, 725},
+		},
+		{
+			name: "bounded", from: 100, to: 1000, precision: 0.1, limit: 300,
+			tried: []float64{100, 1000, 550, 325, 212.5, 268.75, 296.875},
+		},
+		{
+			name: "bound passes", from: 100, to: 1000, precision: 0.1, limit: 5000,
+			tried: []float64{100, 1000}, capped: true,
+		},
+		{
+			name: "first value fails", from: 100, precision: 0.1, limit: 50,
+			tried: []float64{100},
+		},
+		{
+			name: "threads", from: 1, precision: 0.01, threads: true, limit: 12,
+			tried: []float64{1, 2, 4, 8, 16, 12, 14, 13},
+		},
+		{
+			name: "threads capped", from: 1024, precision: 0.01, threads: true, limit: 1e9,
+			tried: []float64{1024, 2048, 4096}, capped: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tried []float64
+			capped, err := probe(tt.from, tt.to, tt.precision, tt.threads, func(v float64) (bool, error) {
+				tried = append(tried, v)
+				return v <= tt.limit, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if capped != tt.capped {
+				t.Errorf("capped = %v, want %v", capped, tt.capped)
+			}
+			if !slices.Equal(tried, tt.tried) {
+				t.Errorf("tried %v, want %v", tried, tt.tried)
+			}
+		})
+	}
+}
+
+func TestProbeConverges(t *testing.T) {
+	for _, limit := range []float64{150, 999, 12345, 100000} {
+		best := 0.0
+		_, err := probe(100, 0, 0.01, false, func(v float64) (bool, error) {
+			pass := v <= limit
+			if pass {
+				best = max(best, v)
+			}
+			return pass, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if best > limit || best < limit*0.99 {
+			t.Errorf("limit %g: best passing value %g, want within 1%%", limit, best)
+		}
+	}
+}
+
+func TestProbeStops(t *testing.T) {
+	boom := errors.New("boom")
+	n := 0
+	_, err := probe(100, 0, 0.01, false, func(v float64) (bool, error) {
+		n++
+		if n == 3 {
+			return false, boom
+		}
+		return true, nil
+	})
+	if !errors.Is(err, boom) || n != 3 {
+		t.Errorf("probe returned %v after %d trials, want boom after 3", err, n)
+	}
+
+	n = 0
+	capped, err := probe(1, 0, 0.01, false, func(v float64) (bool, error) {
+		n++
+		return true, nil
+	})
+	if err != nil || !capped || n != maxSearchTrials {
+		t.Errorf("always passing: capped %v, err %v after %d trials, want capped after %d", capped, err, n, maxSearchTrials)
+	}
+}
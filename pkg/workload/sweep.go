@@ -0,0 +1,148 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/db"
+	"tidb-benchmarks/pkg/metrics"
+)
+
+// maxSweepSteps bounds the steps a range expands to, so a typo does not
+// queue hours of runs.
+const maxSweepSteps = 64
+
+// Sweep runs kind once per value of --sweep-threads or --sweep-rates on
+// the same client, each step with its own warmup and --timeout. It stops
+// at the first failed step and returns the steps that completed along
+// with the error.
+func Sweep(ctx context.Context, client db.Client, cfg config.Config, kind Kind) (metrics.Sweep, error) {
+	param, spec := "threads", cfg.SweepThreads
+	if cfg.SweepRates != "" {
+		if spec != "" {
+			return metrics.Sweep{}, fmt.Errorf("sweep-threads and sweep-rates are exclusive")
+		}
+		param, spec = "rate", cfg.SweepRates
+	}
+	values, err := parseSweep(spec, param == "threads")
+	if err != nil {
+		return metrics.Sweep{}, fmt.Errorf("sweep-%s: %w", param, err)
+	}
+	if cfg.SweepMinGain < 0 || cfg.SweepP99Max < 0 {
+		return metrics.Sweep{}, fmt.Errorf("sweep-min-gain and sweep-p99-max must be >= 0")
+	}
+
+	sw := metrics.Sweep{Param: param}
+	for _, v := range values {
+		step := cfg
+		if param == "threads" {
+			step.Threads = int(v)
+		} else {
+			step.Rate = v
+		}
+		stepCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+		s, err := Run(stepCtx, client, step, kind)
+		cancel()
+		if err != nil {
+			markKnee(&sw, cfg)
+			return sw, fmt.Errorf("%s %g: %w", param, v, err)
+		}
+		sw.Name = s.Name
+		sw.Steps = append(sw.Steps, metrics.SweepStep{
+			Value:   v,
+			QPS:     s.QPS,
+			P99Ms:   s.P99Ms,
+			AvgMs:   s.AvgMs,
+			Errors:  s.Errors,
+			Summary: s,
+		})
+	}
+	markKnee(&sw, cfg)
+	return sw, nil
+}
+
+// markKnee marks the last step before the first one that either adds
+// less throughput than --sweep-min-gain asks for or has a p99 above
+// --sweep-p99-max. The gain is per doubling of the swept value, so a step
+// from 8 to 10 threads needs a third of what one from 8 to 16 does.
+func markKnee(sw *metrics.Sweep, cfg config.Config) {
+	maxP99 := float64(cfg.SweepP99Max.Microseconds()) / 1000
+	for i, st := range sw.Steps {
+		if cfg.SweepP99Max > 0 && st.P99Ms > maxP99 {
+			if i == 0 {
+				sw.KneeReason = fmt.Sprintf("p99 above %s from the first step", cfg.SweepP99Max)
+				return
+			}
+			sw.Steps[i-1].Knee = true
+			sw.KneeReason = fmt.Sprintf("p99 above %s at the next step", cfg.SweepP99Max)
+			return
+		}
+		if i == 0 {
+			continue
+		}
+		prev := sw.Steps[i-1]
+		gain := cfg.SweepMinGain * math.Log2(st.Value/prev.Value)
+		if st.QPS < prev.QPS*(1+gain) {
+			sw.Steps[i-1].Knee = true
+			sw.KneeReason = fmt.Sprintf("next step adds less than %.1f%% QPS", 100*gain)
+			return
+		}
+	}
+}
+
+// parseSweep expands a list "a,b,c" or a geometric range "from:to" or
+// "from:to:factor" (factor 2 by default) into step values.
+func parseSweep(spec string, integer bool) ([]float64, error) {
+	var values []float64
+	if from, rest, ok := strings.Cut(spec, ":"); ok {
+		to, factor := rest, "2"
+		if t, f, ok := strings.Cut(rest, ":"); ok {
+			to, factor = t, f
+		}
+		lo, err1 := strconv.ParseFloat(from, 64)
+		hi, err2 := strconv.ParseFloat(to, 64)
+		f, err3 := strconv.ParseFloat(factor, 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			return nil, fmt.Errorf("invalid range %q, want from:to[:factor]", spec)
+		}
+		if lo <= 0 || hi < lo || f <= 1 {
+			return nil, fmt.Errorf("invalid range %q, want 0 < from <= to and factor > 1", spec)
+		}
+		for i := 0; len(values) <= maxSweepSteps; i++ {
+			v := lo * math.Pow(f, float64(i))
+			if v > hi*(1+1e-9) {
+				break
+			}
+			if integer {
+				v = math.Round(v)
+			}
+			if n := len(values); n == 0 || v != values[n-1] {
+				values = append(values, v)
+			}
+		}
+	} else {
+		for _, s := range strings.Split(spec, ",") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil || v <= 0 {
+				return nil, fmt.Errorf("invalid value %q, want a number > 0", s)
+			}
+			if n := len(values); n > 0 && v <= values[n-1] {
+				return nil, fmt.Errorf("values must increase, %g follows %g", v, values[n-1])
+			}
+			values = append(values, v)
+		}
+	}
+	if len(values) > maxSweepSteps {
+		return nil, fmt.Errorf("more than %d steps", maxSweepSteps)
+	}
+	for _, v := range values {
+		if integer && v != math.Trunc(v) {
+			return nil, fmt.Errorf("thread count %g is not an integer", v)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,130 @@
+package workload
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/metrics"
+)
+
+func TestParseSweep(t *testing.T) {
+	tests := []struct {
+		spec    string
+		integer bool
+		want    []float64
+		wantErr bool
+	}{
+		{spec: "8", integer: true, want: []float64{8}},
+		{spec: "1, 2,4", integer: true, want: []float64{1, 2, 4}},
+		{spec: "8:64", integer: true, want: []float64{8, 16, 32, 64}},
+		{spec: "8:100", integer: true, want: []float64{8, 16, 32, 64}},
+		{spec: "1:10:1.5", integer: true, want: []float64{1, 2, 3, 5, 8}},
+		{spec: "1000:4000:1.5", want: []float64{1000, 1500, 2250, 3375}},
+		{spec: "500.5,1000", want: []float64{500.5, 1000}},
+		{spec: "1:2:1", wantErr: true},
+		{spec: "0:8", wantErr: true},
+		{spec: "8:4", wantErr: true},
+		{spec: "a:8", wantErr: true},
+		{spec: "1:8:x", wantErr: true},
+		{spec: "4,2", wantErr: true},
+		{spec: "2,2", wantErr: true},
+		{spec: "0,2", wantErr: true},
+		{spec: "", wantErr: true},
+		{spec: "1.5,2", integer: true, wantErr: true},
+		{spec: "1:1e30", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSweep(tt.spec, tt.integer)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSweep(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseSweep(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestMarkKnee(t *testing.T) {
+	type step struct {
+		value, qps, p99 float64
+	}
+	tests := []struct {
+		name    string
+		steps   []step
+		minGain float64
+		p99Max  time.Duration
+		knee    int // -1 for none
+	}{
+		{
+			name:    "keeps growing",
+			steps:   []step{{8, 1000, 1}, {16, 1900, 1}, {32, 3500, 1}},
+			minGain: 0.05, knee: -1,
+		},
+		{
+			name:    "flattens",
+			steps:   []step{{8, 1000, 1}, {16, 1900, 1}, {32, 1950, 1}, {64, 1960, 1}},
+			minGain: 0.05, knee: 1,
+		},
+		{
+			// From 8 to 10 threads is a third of a doubling, so 3% is
+			// enough where a doubling would need 5%.
+			name:    "small step scaled down",
+			steps:   []step{{8, 1000, 1}, {10, 1030, 1}, {20, 1060, 1}},
+			minGain: 0.05, knee: 1,
+		},
+		{
+			name:    "small step below the scaled gain",
+			steps:   []step{{8, 1000, 1}, {10, 1010, 1}},
+			minGain: 0.05, knee: 0,
+		},
+		{
+			name:    "large step scaled up",
+			steps:   []step{{8, 1000, 1}, {64, 1120, 1}},
+			minGain: 0.05, knee: 0,
+		},
+		{
+			name:    "p99 crosses",
+			steps:   []step{{8, 1000, 1}, {16, 2000, 5}, {32, 4000, 20}},
+			minGain: 0.05, p99Max: 10 * time.Millisecond, knee: 1,
+		},
+		{
+			name:    "p99 above from the start",
+			steps:   []step{{8, 1000, 20}, {16, 2000, 20}},
+			minGain: 0.05, p99Max: 10 * time.Millisecond, knee: -1,
+		},
+		{
+			name:  "no gain required",
+			steps: []step{{8, 1000, 1}, {16, 1000, 1}, {32, 990, 1}},
+			knee:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sw := metrics.Sweep{Param: "threads"}
+			for _, st := range tt.steps {
+				sw.Steps = append(sw.Steps, metrics.SweepStep{Value: st.value, QPS: st.qps, P99Ms: st.p99})
+			}
+			cfg := config.Default()
+			cfg.SweepMinGain, cfg.SweepP99Max = tt.minGain, tt.p99Max
+			markKnee(&sw, cfg)
+			knee := -1
+			for i, st := range sw.Steps {
+				if st.Knee {
+					if knee >= 0 {
+						t.Fatalf("steps %d and %d both marked", knee, i)
+					}
+					knee = i
+				}
+			}
+			if knee != tt.knee {
+				t.Errorf("knee at step %d (%s), want %d", knee, sw.KneeReason, tt.knee)
+			}
+			if tt.p99Max > 0 && tt.knee < 0 && tt.steps[0].p99 > float64(tt.p99Max.Milliseconds()) && sw.KneeReason == "" {
+				t.Error("no reason given for a p99 above the limit from the first step")
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package workload
+
+import (
+	"fmt"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/keydist"
+)
+
+// tableConfigs returns one config per table with Table set to its name:
+// <table>1..N with --tables N, or cfg itself when --tables is 0. Backends
+// key their statements by cfg.Table, so passing these copies is all it
+// takes to address another table.
+func tableConfigs(cfg config.Config) ([]config.Config, error) {
+	if cfg.Tables < 0 {
+		return nil, fmt.Errorf("tables must be >= 0")
+	}
+	if cfg.Tables == 0 {
+		return []config.Config{cfg}, nil
+	}
+	cfgs := make([]config.Config, cfg.Tables)
+	for i := range cfgs {
+		cfgs[i] = cfg
+		cfgs[i].Table = fmt.Sprintf("%s%d", cfg.Table, i+1)
+	}
+	return cfgs, nil
+}
+
+// table is one target table of a run with its own insert keyspace, so
+// inserts fill each table without gaps, and its own record of deletes.
+// keys draws ids over [1, ks.Latest()].
+type table struct {
+	cfg  config.Config
+	ks   *keyspace
+	live *liveKeys
+	keys keydist.Chooser
+
+	// versions is only set with --verify.
+	versions *versionLog
+}
+
+// newTables builds the run state for every table, whose inserts start at
+// insertStarts[i]. Keys are drawn over the
+// ids each table holds so far, so every table has its own chooser.
+func newTables(cfgs []config.Config, insertStarts []int64) ([]*table, error) {
+	tables := make([]*table, len(cfgs))
+	for i, cfg := range cfgs {
+		t := &table{cfg: cfg, ks: newKeyspace(insertStarts[i]), live: newLiveKeys()}
+		keys, err := keydist.New(cfg, t.ks.Latest)
+		if err != nil {
+			return nil, err
+		}
+		t.keys = keys
+		if cfg.Verify {
+			t.versions = newVersionLog()
+		}
+		tables[i] = t
+	}
+	return tables, nil
+}
@@ -0,0 +1,156 @@
+package workload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"tidb-benchmarks/pkg/metrics"
+)
+
+// versionMagic marks a payload stamped by --verify, so rows loaded by
+// prepare read as version 0.
+var versionMagic = []byte("BVR1")
+
+// versionHeader is the stamped prefix: the magic and a big-endian version.
+const versionHeader = 12
+
+// versionOf returns the version stamped in payload, or 0 if none.
+func versionOf(payload []byte) uint64 {
+	if len(payload) < versionHeader || !bytes.Equal(payload[:4], versionMagic) {
+		return 0
+	}
+	return binary.BigEndian.Uint64(payload[4:versionHeader])
+}
+
+// ackedWrite is a write the database confirmed.
+type ackedWrite struct {
+	version uint64
+	at      time.Time
+}
+
+// versionLog keeps the last two acknowledged writes of every id updated in
+// the run. Only the owning worker writes an id, so versions of one id only
+// grow.
+type versionLog struct {
+	shards [liveShards]struct {
+		mu   sync.Mutex
+		last map[int64][2]ackedWrite
+	}
+}
+
+func newVersionLog() *versionLog {
+	l := &versionLog{}
+	for i := range l.shards {
+		l.shards[i].last = make(map[int64][2]ackedWrite)
+	}
+	return l
+}
+
+func (l *versionLog) ack(id int64, w ackedWrite) {
+	s := &l.shards[uint64(id)%liveShards]
+	s.mu.Lock()
+	prev := s.last[id]
+	s.last[id] = [2]ackedWrite{w, prev[0]}
+	s.mu.Unlock()
+}
+
+// before returns the newest write of id acknowledged before t, which any
+// read that starts at t must see under strong consistency. ok is false
+// when id was not written, or only by writes too recent to judge.
+func (l *versionLog) before(id int64, t time.Time) (ackedWrite, bool) {
+	s := &l.shards[uint64(id)%liveShards]
+	s.mu.Lock()
+	last, found := s.last[id]
+	s.mu.Unlock()
+	if !found {
+		return ackedWrite{}, false
+	}
+	for _, w := range last {
+		if w.version != 0 && w.at.Before(t) {
+			return w, true
+		}
+	}
+	return ackedWrite{}, false
+}
+
+// verifier is one worker's side of --verify: it stamps the versions the
+// worker writes and checks what the worker reads.
+type verifier struct {
+	worker, workers int
+
+	last uint64
+	buf  []byte
+	seen map[*table]map[int64]uint64
+	rec  *metrics.VerifyRecorder
+}
+
+func newVerifier(worker, workers int, payload []byte) *verifier {
+	return &verifier{
+		worker:  worker,
+		workers: workers,
+		buf:     append([]byte(nil), payload...),
+		seen:    make(map[*table]map[int64]uint64),
+		rec:     metrics.NewVerifyRecorder(),
+	}
+}
+
+// own moves id to the nearest id in [1, tableSize] this worker writes.
+// Giving every id a single writer keeps its versions ordered, so an older
+// version read back is stale rather than a lost race between writers.
+// Run makes sure there are at least as many ids as workers; otherwise the
+// result is clamped into the table and may belong to another worker.
+func (v *verifier) own(id, tableSize int64) int64 {
+	n := int64(v.workers)
+	id += int64(v.worker) - id%n
+	for id > tableSize {
+		id -= n
+	}
+	for id < 1 {
+		id += n
+	}
+	return min(id, tableSize)
+}
+
+// owns reports whether this worker is the writer of id.
+func (v *verifier) owns(id int64) bool {
+	return id%int64(v.workers) == int64(v.worker)
+}
+
+// stamp writes the next version into the worker's payload and returns
+// both. Versions are wall-clock nanoseconds, bumped to stay increasing.
+func (v *verifier) stamp() (uint64, []byte) {
+	ver := max(uint64(time.Now().UnixNano()), v.last+1)
+	v.last = ver
+	copy(v.buf, versionMagic)
+	binary.BigEndian.PutUint64(v.buf[4:versionHeader], ver)
+	return ver, v.buf
+}
+
+// check classifies a read of id in t that started at start.
+func (v *verifier) check(t *table, id int64, payload []byte, start time.Time) {
+	got := versionOf(payload)
+
+	var (
+		stale     bool
+		staleness time.Duration
+	)
+	if w, ok := t.versions.before(id, start); ok && got < w.version {
+		stale = true
+		staleness = start.Sub(w.at)
+	}
+	// The worker's own writes all finished before it started this read.
+	ryw := stale && v.owns(id)
+
+	seen := v.seen[t]
+	if seen == nil {
+		seen = make(map[int64]uint64)
+		v.seen[t] = seen
+	}
+	monotonic := got < seen[id]
+	if !monotonic {
+		seen[id] = got
+	}
+	v.rec.RecordRead(stale, staleness, ryw, monotonic)
+}
@@ -0,0 +1,169 @@
+package workload
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestVerifierOwn(t *testing.T) {
+	tests := []struct {
+		workers, worker int
+		id, tableSize   int64
+		want            int64
+	}{
+		{1, 0, 7, 100, 7},
+		{4, 1, 6, 100, 5},
+		{4, 1, 5, 100, 5},
+		{4, 0, 1, 100, 4},
+		{4, 3, 100, 100, 99},
+		{4, 2, 99, 99, 98},
+		{3, 0, 2, 3, 3},
+		{5, 4, 1, 5, 4},
+		// More workers than ids, which Run refuses: the id stays in the
+		// table even though it is not the worker's.
+		{4, 3, 1, 3, 3},
+		{4, 0, 2, 3, 3},
+	}
+	for _, tt := range tests {
+		v := newVerifier(tt.worker, tt.workers, nil)
+		got := v.own(tt.id, tt.tableSize)
+		if got != tt.want {
+			t.Errorf("worker %d of %d: own(%d, %d) = %d, want %d", tt.worker, tt.workers, tt.id, tt.tableSize, got, tt.want)
+		}
+		if got < 1 || got > tt.tableSize {
+			t.Errorf("worker %d of %d: own(%d, %d) = %d, outside the table", tt.worker, tt.workers, tt.id, tt.tableSize, got)
+		}
+	}
+}
+
+func TestVerifierOwnEveryID(t *testing.T) {
+	for _, size := range []int64{4, 5, 17, 100} {
+		for workers := 1; int64(workers) <= size && workers <= 8; workers++ {
+			for worker := 0; worker < workers; worker++ {
+				v := newVerifier(worker, workers, nil)
+				for id := int64(1); id <= size; id++ {
+					got := v.own(id, size)
+					if got < 1 || got > size || !v.owns(got) {
+						t.Fatalf("worker %d of %d, size %d: own(%d) = %d", worker, workers, size, id, got)
+					}
+				}
+			}
+		}
+	}
+}
+
+// stamped returns a payload carrying version ver, as an update with
+// --verify writes it.
+func stamped(ver uint64) []byte {
+	p := make([]byte, versionHeader)
+	copy(p, versionMagic)
+	binary.BigEndian.PutUint64(p[4:], ver)
+	return p
+}
+
+func TestVerifierCheck(t *testing.T) {
+	base := time.Unix(1000, 0)
+	type event struct {
+		write bool
+		id    int64
+		ver   uint64
+		at    time.Duration
+	}
+	tests := []struct {
+		name   string
+		events []event
+		// The verifier is worker 0 of 2, so it owns the even ids.
+		stale, ryw, monotonic int64
+		staleness             time.Duration
+	}{
+		{
+			name:   "unwritten id reads as loaded",
+			events: []event{{id: 2, ver: 0, at: time.Second}},
+		},
+		{
+			name: "reads the latest write",
+			events: []event{
+				{write: true, id: 3, ver: 10, at: 0},
+				{id: 3, ver: 10, at: time.Second},
+			},
+		},
+		{
+			name: "write acknowledged after the read started",
+			events: []event{
+				{write: true, id: 3, ver: 10, at: 2 * time.Second},
+				{id: 3, ver: 0, at: time.Second},
+			},
+		},
+		{
+			name: "stale read of another worker's id",
+			events: []event{
+				{write: true, id: 3, ver: 10, at: 0},
+				{id: 3, ver: 0, at: 3 * time.Second},
+			},
+			stale: 1, staleness: 3 * time.Second,
+		},
+		{
+			name: "stale read of an own id",
+			events: []event{
+				{write: true, id: 4, ver: 10, at: 0},
+				{write: true, id: 4, ver: 20, at: time.Second},
+				{id: 4, ver: 10, at: 2 * time.Second},
+			},
+			stale: 1, ryw: 1, staleness: time.Second,
+		},
+		{
+			name: "older version than read before",
+			events: []event{
+				{write: true, id: 3, ver: 10, at: 0},
+				{write: true, id: 3, ver: 20, at: 3 * time.Second},
+				{id: 3, ver: 20, at: 1 * time.Second},
+				{id: 3, ver: 10, at: 2 * time.Second},
+			},
+			monotonic: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tbl := &table{versions: newVersionLog()}
+			v := newVerifier(0, 2, nil)
+			for _, e := range tt.events {
+				if e.write {
+					tbl.versions.ack(e.id, ackedWrite{version: e.ver, at: base.Add(e.at)})
+					continue
+				}
+				v.check(tbl, e.id, stamped(e.ver), base.Add(e.at))
+			}
+			res := v.rec.Result("test")
+			if res.StaleReads != tt.stale || res.ReadYourWritesViolations != tt.ryw || res.MonotonicReadViolations != tt.monotonic {
+				t.Errorf("stale, ryw, monotonic = %d, %d, %d, want %d, %d, %d",
+					res.StaleReads, res.ReadYourWritesViolations, res.MonotonicReadViolations, tt.stale, tt.ryw, tt.monotonic)
+			}
+			if tt.staleness > 0 {
+				want := float64(tt.staleness.Microseconds()) / 1000
+				if res.Staleness == nil || res.Staleness.P50Ms < want*0.99 || res.Staleness.P50Ms > want*1.01 {
+					t.Errorf("staleness = %+v, want %gms", res.Staleness, want)
+				}
+			}
+		})
+	}
+}
+
+func TestVersionOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		want    uint64
+	}{
+		{"stamped", stamped(42), 42},
+		{"stamped with a tail", append(stamped(7), "rest"...), 7},
+		{"loaded by prepare", []byte("abcdefghijklmnop"), 0},
+		{"short", []byte("BVR1"), 0},
+		{"empty", nil, 0},
+	}
+	for _, tt := range tests {
+		if got := versionOf(tt.payload); got != tt.want {
+			t.Errorf("%s: versionOf() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,11 +1,14 @@
 package workload
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/spf13/pflag"
 
 	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/metrics"
+	"tidb-benchmarks/pkg/util"
 )
 
 type Kind string
@@ -14,15 +17,213 @@ const (
 	KindReadOnly  Kind = "read-only"
 	KindWriteOnly Kind = "write-only"
 	KindMixed     Kind = "mixed"
+	KindRangeScan Kind = "range-scan"
+
+	KindIndexLookup Kind = "index-lookup"
+
+	// KindOLTPReadWrite is sysbench's oltp_read_write transaction.
+	KindOLTPReadWrite Kind = "oltp-read-write"
+
+	// KindBank moves money between accounts and checks the total.
+	KindBank Kind = "bank"
+
+	// KindCAS runs conditional updates of a version kept in k.
+	KindCAS Kind = "cas"
+
+	// YCSB core workloads.
+	KindYCSBA Kind = "ycsb-a"
+	KindYCSBB Kind = "ycsb-b"
+	KindYCSBC Kind = "ycsb-c"
+	KindYCSBD Kind = "ycsb-d"
+	KindYCSBE Kind = "ycsb-e"
+	KindYCSBF Kind = "ycsb-f"
 )
 
+// DefaultKeyDist returns the request distribution a workload is defined
+// with, or "" when it has none and follows --key-dist.
+func (k Kind) DefaultKeyDist() config.KeyDist {
+	switch k {
+	case KindYCSBA, KindYCSBB, KindYCSBC, KindYCSBE, KindYCSBF:
+		return config.KeyDistScrambled
+	case KindYCSBD:
+		return config.KeyDistLatest
+	default:
+		return ""
+	}
+}
+
+// mix holds the proportion of each operation in a workload. Proportions
+// need not sum to 1; they are normalised when picking.
+type mix struct {
+	read            float64
+	update          float64
+	insert          float64
+	scan            float64
+	indexLookup     float64
+	delete          float64
+	transaction     float64
+	transfer        float64
+	readModifyWrite float64
+	cas             float64
+
+	// scanUniform draws each scan length from 1..scan-length, as YCSB E
+	// does; otherwise every scan asks for scan-length rows.
+	scanUniform bool
+}
+
+// Check returns an error when kind is not a run workload or cfg gives it
+// an invalid mix, so that callers can fail before anything runs.
+func Check(kind Kind, cfg config.Config) error {
+	_, err := mixFor(kind, cfg)
+	return err
+}
+
+func mixFor(kind Kind, cfg config.Config) (mix, error) {
+	switch kind {
+	case KindReadOnly, KindYCSBC:
+		return mix{read: 1}, nil
+	case KindWriteOnly:
+		return mix{update: 1}, nil
+	case KindMixed:
+		r := clampRatio(cfg.ReadRatio)
+		s, i, d := clampRatio(cfg.ScanRatio), clampRatio(cfg.InsertRatio), clampRatio(cfg.DeleteRatio)
+		rest := 1 - s - i - d
+		if rest < -1e-9 {
+			return mix{}, fmt.Errorf("scan-ratio, insert-ratio and delete-ratio must sum to at most 1")
+		}
+		rest = max(rest, 0)
+		return mix{read: rest * r, update: rest * (1 - r), scan: s, insert: i, delete: d}, nil
+	case KindRangeScan:
+		return mix{scan: 1}, nil
+	case KindIndexLookup:
+		return mix{indexLookup: 1}, nil
+	case KindOLTPReadWrite:
+		return mix{transaction: 1}, nil
+	case KindBank:
+		return mix{transfer: 1}, nil
+	case KindCAS:
+		return mix{cas: 1}, nil
+	case KindYCSBA:
+		return mix{read: 0.5, update: 0.5}, nil
+	case KindYCSBB:
+		return mix{read: 0.95, update: 0.05}, nil
+	case KindYCSBD:
+		return mix{read: 0.95, insert: 0.05}, nil
+	case KindYCSBE:
+		return mix{scan: 0.95, insert: 0.05, scanUniform: true}, nil
+	case KindYCSBF:
+		return mix{read: 0.5, readModifyWrite: 0.5}, nil
+	default:
+		return mix{}, fmt.Errorf("unsupported workload kind: %s", kind)
+	}
+}
+
+func (m mix) pick(rng *util.SplitMix64) metrics.Op {
+	choices := [...]struct {
+		o metrics.Op
+		p float64
+	}{
+		{metrics.OpRead, m.read},
+		{metrics.OpUpdate, m.update},
+		{metrics.OpInsert, m.insert},
+		{metrics.OpScan, m.scan},
+		{metrics.OpIndexLookup, m.indexLookup},
+		{metrics.OpDelete, m.delete},
+		{metrics.OpTransaction, m.transaction},
+		{metrics.OpTransfer, m.transfer},
+		{metrics.OpReadModifyWrite, m.readModifyWrite},
+		{metrics.OpCAS, m.cas},
+	}
+	total := 0.0
+	for _, c := range choices {
+		total += c.p
+	}
+	x := rng.Float64() * total
+	last := metrics.OpRead
+	for _, c := range choices {
+		if c.p <= 0 {
+			continue
+		}
+		if x < c.p {
+			return c.o
+		}
+		x -= c.p
+		last = c.o
+	}
+	// Rounding can leave x just past the final bucket.
+	return last
+}
+
+func BindPrepareFlags(fs *pflag.FlagSet, cfg *config.Config) {
+	fs.IntVar(&cfg.BatchSize, "batch-size", cfg.BatchSize, "Rows per insert when loading; > 1 enables bulk mode")
+	fs.Int64Var(&cfg.ChunkSize, "chunk-size", cfg.ChunkSize, "Rows per checkpointed chunk (0 = pick from table size and threads)")
+	fs.StringVar(&cfg.Checkpoint, "checkpoint", cfg.Checkpoint, "Checkpoint file, written so that --resume can continue the load (default <table>.checkpoint, or <table>.<start>-<end>.checkpoint with --id-range)")
+	fs.BoolVar(&cfg.Resume, "resume", cfg.Resume, "Skip truncate and load only the chunks the checkpoint does not mark done")
+	fs.StringVar(&cfg.IDRange, "id-range", cfg.IDRange, "Load only ids start:end (inclusive) without truncating, to split one table across loaders")
+}
+
 func BindRunFlags(fs *pflag.FlagSet, cfg *config.Config) {
 	fs.DurationVar(&cfg.Time, "time", cfg.Time, "Workload duration (e.g. 30s)")
+	fs.DurationVar(&cfg.Warmup, "warmup", cfg.Warmup, "Warmup duration before measuring")
+	fs.StringVar((*string)(&cfg.KeyDist), "key-dist", string(cfg.KeyDist), "Key distribution: uniform|zipfian|scrambled-zipfian|hotspot|latest|sequential (YCSB workloads default to their own)")
+	fs.Float64Var(&cfg.ZipfianTheta, "zipfian-theta", cfg.ZipfianTheta, "Skew for the zipfian, scrambled-zipfian and latest distributions (0..1, exclusive)")
+	fs.Float64Var(&cfg.HotspotFraction, "hotspot-fraction", cfg.HotspotFraction, "Fraction of ids in the hot set for the hotspot distribution")
+	fs.Float64Var(&cfg.HotspotProbability, "hotspot-probability", cfg.HotspotProbability, "Fraction of operations that hit the hot set for the hotspot distribution")
+	fs.Float64Var(&cfg.Rate, "rate", cfg.Rate, "Target throughput in ops/sec across all workers; enables open-loop mode (0 = closed loop)")
+	fs.DurationVar(&cfg.ReportInterval, "report-interval", cfg.ReportInterval, "Print throughput and latency every interval while running (0 = off)")
+	fs.Int64Var(&cfg.MaxErrors, "max-errors", cfg.MaxErrors, "Keep running after failed operations until more than this many errors (0 = stop at the first error unless --max-error-rate is set)")
+	fs.Float64Var(&cfg.MaxErrorRate, "max-error-rate", cfg.MaxErrorRate, "Keep running after failed operations until the error fraction exceeds this (0..1, 0 = off)")
+	fs.IntVar(&cfg.RetryAttempts, "retry-attempts", cfg.RetryAttempts, "Tries per operation, retrying errors of the --retry-on classes (1 = no retries)")
+	fs.DurationVar(&cfg.RetryBackoff, "retry-backoff", cfg.RetryBackoff, "Backoff before the first retry, doubled for each further one, with full jitter")
+	fs.DurationVar(&cfg.RetryMaxBackoff, "retry-max-backoff", cfg.RetryMaxBackoff, "Upper bound on the backoff before one retry")
+	fs.StringSliceVar(&cfg.RetryOn, "retry-on", cfg.RetryOn, "Error classes to retry: timeout,conflict,unavailable,not-found,other (empty = the backend's default)")
+	fs.BoolVar(&cfg.Verify, "verify", cfg.Verify, "Stamp a version into every update and check reads for stale, read-your-writes and monotonic-read violations")
+	fs.Int64Var(&cfg.InsertStart, "insert-start", cfg.InsertStart, "First id used by inserts during the run (0 = after the highest id in the table, at least table-size+1)")
 }
 
 func BindMixedFlags(fs *pflag.FlagSet, cfg *config.Config) {
 	fs.Float64Var(&cfg.ReadRatio, "read-ratio", cfg.ReadRatio, "Read ratio for mixed workload (0..1)")
-	fs.DurationVar(&cfg.Warmup, "warmup", cfg.Warmup, "Warmup duration before measuring")
+	fs.Float64Var(&cfg.ScanRatio, "scan-ratio", cfg.ScanRatio, "Fraction of mixed operations that are range scans (0..1); reads and updates share the rest by --read-ratio")
+	fs.Float64Var(&cfg.InsertRatio, "insert-ratio", cfg.InsertRatio, "Fraction of mixed operations that insert new ids (0..1)")
+	fs.Float64Var(&cfg.DeleteRatio, "delete-ratio", cfg.DeleteRatio, "Fraction of mixed operations that delete existing ids (0..1)")
+	fs.BoolVar(&cfg.CountDeletedReads, "count-deleted-reads", cfg.CountDeletedReads, "Count reads that miss an id deleted during the run as errors")
+}
+
+func BindScanFlags(fs *pflag.FlagSet, cfg *config.Config) {
+	fs.IntVar(&cfg.ScanLength, "scan-length", cfg.ScanLength, "Rows per range scan (ycsb-e draws a uniform 1..N per scan)")
+}
+
+func BindBankFlags(fs *pflag.FlagSet, cfg *config.Config) {
+	fs.Int64Var(&cfg.BankAccounts, "accounts", cfg.BankAccounts, "Number of bank accounts, stored in <table>_bank")
+	fs.Int64Var(&cfg.BankBalance, "initial-balance", cfg.BankBalance, "Opening balance of every account")
+	fs.StringVar(&cfg.BankMode, "bank-mode", cfg.BankMode, "How transfers run: MySQL/TiDB lock|snapshot, Cassandra lwt|batch (empty = lock or lwt)")
+	fs.DurationVar(&cfg.BankCheckInterval, "check-interval", cfg.BankCheckInterval, "Verify the total balance this often while running (0 = only at the end)")
+}
+
+func BindRepeatFlags(fs *pflag.FlagSet, cfg *config.Config) {
+	fs.IntVar(&cfg.Repeat, "repeat", cfg.Repeat, "Run the workload this many times and report mean, stddev and 95% confidence interval")
+	fs.DurationVar(&cfg.RepeatCooldown, "cooldown", cfg.RepeatCooldown, "Pause between repeats")
+}
+
+func BindSweepFlags(fs *pflag.FlagSet, cfg *config.Config) {
+	fs.StringVar(&cfg.SweepThreads, "sweep-threads", cfg.SweepThreads, "Run once per thread count: a list (8,16,32) or a geometric range (8:256 doubles, 8:256:4 multiplies by 4)")
+	fs.StringVar(&cfg.SweepRates, "sweep-rates", cfg.SweepRates, "Run once per --rate target, given like --sweep-threads (e.g. 1000:64000)")
+	fs.DurationVar(&cfg.SweepP99Max, "sweep-p99-max", cfg.SweepP99Max, "Mark the knee before the first step whose p99 exceeds this (0 = off)")
+	fs.Float64Var(&cfg.SweepMinGain, "sweep-min-gain", cfg.SweepMinGain, "Mark the knee before the first step that adds less than this fraction of QPS per doubling of the swept value")
+}
+
+func BindSearchFlags(fs *pflag.FlagSet, cfg *config.Config) {
+	fs.DurationVar(&cfg.SLOP99, "slo-p99", cfg.SLOP99, "Latency objective: a trial passes when its p99 is at most this")
+	fs.StringVar(&cfg.SearchParam, "search-param", cfg.SearchParam, "What to search: rate|threads (rate runs open-loop at --threads workers)")
+	fs.Float64Var(&cfg.SearchMin, "search-min", cfg.SearchMin, "Lowest rate or thread count tried")
+	fs.Float64Var(&cfg.SearchMax, "search-max", cfg.SearchMax, "Highest rate or thread count tried (0 = double from --search-min until a trial fails, at most 4096 threads)")
+	fs.Float64Var(&cfg.SearchPrecision, "search-precision", cfg.SearchPrecision, "Stop when the passing and failing values are within this fraction of each other")
+	fs.DurationVar(&cfg.SearchTrialTime, "trial-time", cfg.SearchTrialTime, "Measured duration of each trial, after --warmup")
+}
+
+func BindCASFlags(fs *pflag.FlagSet, cfg *config.Config) {
+	fs.Int64Var(&cfg.CASKeys, "cas-keys", cfg.CASKeys, "Number of rows updated, stored in <table>_cas; fewer rows mean more contention")
+	fs.IntVar(&cfg.CASRetries, "cas-retries", cfg.CASRetries, "Retries of a compare-and-set that lost to another writer before giving up")
 }
 
 func clampRatio(x float64) float64 {
@@ -0,0 +1,79 @@
+package workload
+
+import (
+	"math"
+	"testing"
+
+	"tidb-benchmarks/pkg/config"
+	"tidb-benchmarks/pkg/metrics"
+	"tidb-benchmarks/pkg/util"
+)
+
+// picks is the sample size of TestMixPick.
+const picks = 100000
+
+func TestMixPick(t *testing.T) {
+	mixed := func(read, scan, insert, del float64) config.Config {
+		cfg := config.Default()
+		cfg.ReadRatio, cfg.ScanRatio, cfg.InsertRatio, cfg.DeleteRatio = read, scan, insert, del
+		return cfg
+	}
+	tests := []struct {
+		kind Kind
+		cfg  config.Config
+		want map[metrics.Op]float64
+	}{
+		{KindReadOnly, config.Default(), map[metrics.Op]float64{metrics.OpRead: 1}},
+		{KindWriteOnly, config.Default(), map[metrics.Op]float64{metrics.OpUpdate: 1}},
+		{KindMixed, mixed(0.8, 0, 0, 0), map[metrics.Op]float64{metrics.OpRead: 0.8, metrics.OpUpdate: 0.2}},
+		{KindMixed, mixed(0.5, 0.2, 0.1, 0.1), map[metrics.Op]float64{
+			metrics.OpRead: 0.3, metrics.OpUpdate: 0.3, metrics.OpScan: 0.2, metrics.OpInsert: 0.1, metrics.OpDelete: 0.1,
+		}},
+		{KindMixed, mixed(2, 0, 0, -1), map[metrics.Op]float64{metrics.OpRead: 1}},
+		{KindYCSBA, config.Default(), map[metrics.Op]float64{metrics.OpRead: 0.5, metrics.OpUpdate: 0.5}},
+		{KindYCSBB, config.Default(), map[metrics.Op]float64{metrics.OpRead: 0.95, metrics.OpUpdate: 0.05}},
+		{KindYCSBC, config.Default(), map[metrics.Op]float64{metrics.OpRead: 1}},
+		{KindYCSBD, config.Default(), map[metrics.Op]float64{metrics.OpRead: 0.95, metrics.OpInsert: 0.05}},
+		{KindYCSBE, config.Default(), map[metrics.Op]float64{metrics.OpScan: 0.95, metrics.OpInsert: 0.05}},
+		{KindYCSBF, config.Default(), map[metrics.Op]float64{metrics.OpRead: 0.5, metrics.OpReadModifyWrite: 0.5}},
+		{KindCAS, config.Default(), map[metrics.Op]float64{metrics.OpCAS: 1}},
+	}
+	for _, tt := range tests {
+		m, err := mixFor(tt.kind, tt.cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.kind, err)
+		}
+		rng := util.NewSplitMix64(1)
+		counts := make(map[metrics.Op]int)
+		for i := 0; i < picks; i++ {
+			counts[m.pick(rng)]++
+		}
+		for op, n := range counts {
+			if _, ok := tt.want[op]; !ok {
+				t.Errorf("%s: picked %s %d times, want never", tt.kind, op, n)
+			}
+		}
+		for op, want := range tt.want {
+			if got := float64(counts[op]) / picks; math.Abs(got-want) > 0.01 {
+				t.Errorf("%s: picked %s %.3f of the time, want %.3f", tt.kind, op, got, want)
+			}
+		}
+	}
+}
+
+func TestMixForRejects(t *testing.T) {
+	over := config.Default()
+	over.ScanRatio, over.InsertRatio, over.DeleteRatio = 0.5, 0.4, 0.2
+	tests := []struct {
+		kind Kind
+		cfg  config.Config
+	}{
+		{"nope", config.Default()},
+		{KindMixed, over},
+	}
+	for _, tt := range tests {
+		if err := Check(tt.kind, tt.cfg); err == nil {
+			t.Errorf("Check(%s) succeeded, want an error", tt.kind)
+		}
+	}
+}